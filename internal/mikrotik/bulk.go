@@ -0,0 +1,248 @@
+package mikrotik
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// bulkDeleteSize is the maximum number of IDs removed by a single request
+const bulkDeleteSize = 100
+
+// bulkScriptProbe is executed once to check whether the router can run bulk scripts and return their output
+const bulkScriptProbe = `:put "bulk-ok"`
+
+// CreateDNSRecords creates the records in batches of BulkCreateSize, each batch being a single script run
+// through /rest/execute. Records the script failed to create are retried one by one, to report the reason
+// of the failure. If the router cannot run bulk scripts, every record is created one by one instead.
+func (c *MikrotikApiClient) CreateDNSRecords(ctx context.Context, records []*DNSRecord) ([]*DNSRecord, error) {
+	if c.BulkCreateSize <= 1 || len(records) <= 1 || !c.bulkSupported(ctx) {
+		return createDNSRecordsOneByOne(ctx, c, records)
+	}
+
+	created := make([]*DNSRecord, 0, len(records))
+	var errs []error
+	for batch := range slices.Chunk(records, c.BulkCreateSize) {
+		batchCreated, err := c.createDNSRecordsBatch(ctx, batch)
+		created = append(created, batchCreated...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return created, errors.Join(errs...)
+}
+
+// createDNSRecordsBatch creates up to BulkCreateSize records in a single request
+func (c *MikrotikApiClient) createDNSRecordsBatch(ctx context.Context, records []*DNSRecord) ([]*DNSRecord, error) {
+	log.Infof("creating %d DNS records in bulk", len(records))
+
+	script, err := bulkCreateScript(records)
+	if err != nil {
+		return make([]*DNSRecord, len(records)), err
+	}
+
+	output, err := c.executeScript(ctx, script)
+	if err != nil {
+		// The script may or may not have run, so creating the records again could duplicate them
+		var errs []error
+		for _, record := range records {
+			errs = append(errs, &DNSRecordError{Record: record, Err: fmt.Errorf("bulk creation failed: %w", err)})
+		}
+		return make([]*DNSRecord, len(records)), errors.Join(errs...)
+	}
+
+	ids, failed := parseBulkCreateOutput(output, len(records))
+
+	created := make([]*DNSRecord, len(records))
+	var errs []error
+	for i, record := range records {
+		switch {
+		case ids[i] != "":
+			createdRecord := *record
+			createdRecord.ID = ids[i]
+			created[i] = &createdRecord
+		case failed[i]:
+			// The script does not tell why a record failed, so retry it on its own to get the router's error
+			log.Debugf("bulk creation of record %+v failed, retrying it on its own", record)
+			if created[i], err = c.CreateDNSRecord(ctx, record); err != nil {
+				errs = append(errs, &DNSRecordError{Record: record, Err: err})
+			}
+		default:
+			errs = append(errs, &DNSRecordError{Record: record, Err: fmt.Errorf("bulk creation reported no result")})
+		}
+	}
+
+	log.Infof("created %d out of %d DNS records in bulk", len(records)-len(errs), len(records))
+	return created, errors.Join(errs...)
+}
+
+// DeleteDNSRecords removes the records with a single remove command per batch of IDs.
+// RouterOS rejects the whole command if any ID does not exist anymore, in which case the batch is removed
+// one record at a time, ignoring the records that are already gone.
+func (c *MikrotikApiClient) DeleteDNSRecords(ctx context.Context, ids []string) error {
+	var errs []error
+	for batch := range slices.Chunk(ids, bulkDeleteSize) {
+		if err := c.deleteDNSRecordsBatch(ctx, batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// deleteDNSRecordsBatch removes up to bulkDeleteSize records in a single request
+func (c *MikrotikApiClient) deleteDNSRecordsBatch(ctx context.Context, ids []string) error {
+	if len(ids) > 1 {
+		log.Infof("deleting %d DNS records in bulk (IDs: %s)", len(ids), strings.Join(ids, ","))
+
+		jsonBody, err := json.Marshal(map[string]string{".id": strings.Join(ids, ",")})
+		if err != nil {
+			return fmt.Errorf("error marshalling IDs: %w", err)
+		}
+
+		resp, err := c.doRequest(ctx, http.MethodPost, "ip/dns/static/remove", "", bytes.NewReader(jsonBody))
+		if err == nil {
+			resp.Body.Close()
+			return nil
+		}
+		if !IsNotFound(err) {
+			return fmt.Errorf("error deleting DNS records: %w", err)
+		}
+		log.Debugf("some of the DNS records to delete are already gone, deleting them one by one: %v", err)
+	}
+
+	return deleteDNSRecordsOneByOne(ctx, c, ids)
+}
+
+// bulkSupported checks, once, whether the router can run scripts and return their output.
+// Transient failures are not remembered, so the check is attempted again on the next call.
+func (c *MikrotikApiClient) bulkSupported(ctx context.Context) bool {
+	c.bulkMu.Lock()
+	defer c.bulkMu.Unlock()
+
+	if c.bulkSupport != nil {
+		return *c.bulkSupport
+	}
+
+	// Only remember the outcome if the router answered, rather than if it could not be reached
+	output, err := c.executeScript(ctx, bulkScriptProbe)
+	if err != nil && (isTransientError(err) || errors.Is(err, ErrCircuitOpen) || ctx.Err() != nil) {
+		log.Warnf("failed to check whether router %s supports bulk operations, using one request per record: %v", c.BaseUrl, err)
+		return false
+	}
+
+	supported := err == nil && strings.TrimSpace(output) == "bulk-ok"
+	switch {
+	case supported:
+	case IsPermissionDenied(err):
+		log.Warnf("the configured user is not allowed to run scripts on router %s, using one request per record: %v", c.BaseUrl, err)
+	default:
+		log.Warnf("router %s cannot run bulk operations, using one request per record: %v", c.BaseUrl, err)
+	}
+	c.bulkSupport = &supported
+
+	return supported
+}
+
+// executeScript runs a script on the router and returns its output
+func (c *MikrotikApiClient) executeScript(ctx context.Context, script string) (string, error) {
+	jsonBody, err := json.Marshal(map[string]any{"script": script, "as-string": ""})
+	if err != nil {
+		return "", fmt.Errorf("error marshalling script: %w", err)
+	}
+
+	resp, err := c.doRequest(ctx, http.MethodPost, "execute", "", bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Ret *string `json:"ret"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("error decoding script output: %w", err)
+	}
+	if result.Ret == nil {
+		return "", fmt.Errorf("router did not return the script output")
+	}
+
+	return *result.Ret, nil
+}
+
+// bulkCreateScript generates a script adding every record, printing "+<index>=<id>" for each created record
+// and "-<index>" for each failed one.
+func bulkCreateScript(records []*DNSRecord) (string, error) {
+	var script strings.Builder
+	for i, record := range records {
+		fields, err := record.fields()
+		if err != nil {
+			return "", err
+		}
+
+		var args []string
+		for _, key := range slices.Sorted(maps.Keys(fields)) {
+			if key == ".id" {
+				continue
+			}
+			args = append(args, fmt.Sprintf("%s=%s", key, routerOSScriptString(fields[key])))
+		}
+
+		fmt.Fprintf(&script, ":do { :put (\"+%d=\" . [/ip dns static add %s]) } on-error={ :put \"-%d\" }\n", i, strings.Join(args, " "), i)
+	}
+
+	return script.String(), nil
+}
+
+// parseBulkCreateOutput maps the output of a bulk create script to the ID of each created record,
+// and whether each record failed. Records with neither have an unknown outcome.
+func parseBulkCreateOutput(output string, count int) ([]string, []bool) {
+	ids := make([]string, count)
+	failed := make([]bool, count)
+
+	for line := range strings.Lines(output) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		index, id, _ := strings.Cut(line[1:], "=")
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 || i >= count {
+			log.Debugf("ignoring unexpected bulk script output: %s", line)
+			continue
+		}
+
+		switch line[0] {
+		case '+':
+			ids[i] = id
+		case '-':
+			failed[i] = true
+		}
+	}
+
+	return ids, failed
+}
+
+// routerOSScriptString quotes a value as a RouterOS script string literal
+func routerOSScriptString(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		`$`, `\$`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+	)
+	return `"` + replacer.Replace(value) + `"`
+}
@@ -0,0 +1,275 @@
+package mikrotik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newBulkTestServer serves /rest/execute, failing the records whose name starts with "bad", and PUT requests.
+// If bulk is false, the router rejects scripts like a user lacking the required policies.
+func newBulkTestServer(t *testing.T, bulk bool, executeCalls, putCalls *int) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/execute":
+			*executeCalls++
+			if !bulk {
+				http.Error(w, `{"error":400,"message":"Bad Request","detail":"not enough permissions"}`, http.StatusBadRequest)
+				return
+			}
+
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("Failed to decode script: %v", err)
+			}
+			if body["script"] == bulkScriptProbe {
+				_, _ = w.Write([]byte(`{"ret":"bulk-ok"}`))
+				return
+			}
+
+			var output strings.Builder
+			for i, line := range strings.Split(strings.TrimSpace(body["script"]), "\n") {
+				if strings.Contains(line, `name="bad`) {
+					fmt.Fprintf(&output, "-%d\n", i)
+				} else {
+					fmt.Fprintf(&output, "+%d=*%X\n", i, 100+i)
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"ret": output.String()})
+
+		case r.Method == http.MethodPut && r.URL.Path == "/rest/ip/dns/static":
+			*putCalls++
+			var record DNSRecord
+			if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+				t.Errorf("Failed to decode record: %v", err)
+			}
+			if strings.HasPrefix(record.Name, "bad") {
+				http.Error(w, `{"error":400,"message":"Bad Request","detail":"failure: invalid value"}`, http.StatusBadRequest)
+				return
+			}
+			record.ID = "*1"
+			_ = json.NewEncoder(w).Encode(record)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestCreateDNSRecordsBulk(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		bulk                 bool
+		bulkCreateSize       int
+		expectedExecuteCalls int
+		expectedPutCalls     int
+	}{
+		{
+			name:                 "records are created in batches",
+			bulk:                 true,
+			bulkCreateSize:       2,
+			expectedExecuteCalls: 3, // probe and 2 batches
+			expectedPutCalls:     1, // failed record retried on its own
+		},
+		{
+			name:                 "router without bulk support",
+			bulk:                 false,
+			bulkCreateSize:       2,
+			expectedExecuteCalls: 1, // probe
+			expectedPutCalls:     3,
+		},
+		{
+			name:             "bulk creation disabled",
+			bulk:             true,
+			bulkCreateSize:   0,
+			expectedPutCalls: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var executeCalls, putCalls int
+			server := newBulkTestServer(t, tc.bulk, &executeCalls, &putCalls)
+			defer server.Close()
+
+			client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+				BaseUrl:        server.URL,
+				Username:       mockUsername,
+				Password:       mockPassword,
+				SkipTLSVerify:  true,
+				BulkCreateSize: tc.bulkCreateSize,
+			}, &MikrotikDefaults{})
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			records := []*DNSRecord{
+				{Name: "a.example.com", Type: "A", Address: "1.2.3.4"},
+				{Name: "bad.example.com", Type: "A", Address: "1.2.3.5"},
+				{Name: "c.example.com", Type: "TXT", Text: `say "hi" for $5`},
+			}
+			created, err := client.CreateDNSRecords(context.Background(), records)
+
+			var recordErr *DNSRecordError
+			if !errors.As(err, &recordErr) || recordErr.Record != records[1] {
+				t.Fatalf("Expected a DNSRecordError for bad.example.com, got %v", err)
+			}
+			if !strings.Contains(err.Error(), "400 Bad Request") {
+				t.Errorf("Expected the error to include the error reported by the router, got %v", err)
+			}
+			if len(created) != 3 || created[0] == nil || created[1] != nil || created[2] == nil {
+				t.Fatalf("Expected the created records in order, with nil for the failed one, got %+v", created)
+			}
+			if created[0].ID == "" || created[2].Text != records[2].Text {
+				t.Errorf("Expected created records to have an ID and their fields, got %+v", created)
+			}
+
+			if executeCalls != tc.expectedExecuteCalls {
+				t.Errorf("Expected %d script executions, got %d", tc.expectedExecuteCalls, executeCalls)
+			}
+			if putCalls != tc.expectedPutCalls {
+				t.Errorf("Expected %d individual creations, got %d", tc.expectedPutCalls, putCalls)
+			}
+		})
+	}
+}
+
+func TestBulkCreateScript(t *testing.T) {
+	script, err := bulkCreateScript([]*DNSRecord{
+		{Name: "example.com", Type: "A", Address: "1.2.3.4", TTL: "1h"},
+		{Regexp: `.*\.example\.com`, Type: "TXT", Text: "a \"quoted\" $value\n"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := `:do { :put ("+0=" . [/ip dns static add address="1.2.3.4" name="example.com" ttl="1h" type="A"]) } on-error={ :put "-0" }
+:do { :put ("+1=" . [/ip dns static add regexp=".*\\.example\\.com" text="a \"quoted\" \$value\n" type="TXT"]) } on-error={ :put "-1" }
+`
+	if script != expected {
+		t.Errorf("Expected script:\n%s\ngot:\n%s", expected, script)
+	}
+}
+
+func TestParseBulkCreateOutput(t *testing.T) {
+	ids, failed := parseBulkCreateOutput("+0=*1A\r\n-2\n\ngarbage\n+7=*FF\n", 3)
+
+	if ids[0] != "*1A" || ids[1] != "" || ids[2] != "" {
+		t.Errorf("Unexpected IDs: %v", ids)
+	}
+	if failed[0] || failed[1] || !failed[2] {
+		t.Errorf("Unexpected failures: %v", failed)
+	}
+}
+
+func TestDeleteDNSRecordsBulk(t *testing.T) {
+	testCases := []struct {
+		name                string
+		ids                 []string
+		expectedRemoveCalls int
+		expectedDeleteCalls int
+		expectedRemaining   []string
+	}{
+		{
+			name:                "records are removed in a single request",
+			ids:                 []string{"*1", "*2", "*3"},
+			expectedRemoveCalls: 1,
+			expectedRemaining:   []string{"*4"},
+		},
+		{
+			name:                "records already gone are ignored",
+			ids:                 []string{"*1", "*9", "*3"},
+			expectedRemoveCalls: 1,
+			expectedDeleteCalls: 3,
+			expectedRemaining:   []string{"*2", "*4"},
+		},
+		{
+			name:                "single record",
+			ids:                 []string{"*2"},
+			expectedDeleteCalls: 1,
+			expectedRemaining:   []string{"*1", "*3", "*4"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			existing := map[string]bool{"*1": true, "*2": true, "*3": true, "*4": true}
+			var removeCalls, deleteCalls int
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+
+				switch {
+				case r.Method == http.MethodPost && r.URL.Path == "/rest/ip/dns/static/remove":
+					removeCalls++
+					var body map[string]string
+					if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+						t.Errorf("Failed to decode body: %v", err)
+					}
+					ids := strings.Split(body[".id"], ",")
+					for _, id := range ids {
+						if !existing[id] {
+							http.Error(w, `{"error":400,"message":"Bad Request","detail":"no such item"}`, http.StatusBadRequest)
+							return
+						}
+					}
+					for _, id := range ids {
+						delete(existing, id)
+					}
+					_, _ = w.Write([]byte(`[]`))
+
+				case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/rest/ip/dns/static/"):
+					deleteCalls++
+					id := strings.TrimPrefix(r.URL.Path, "/rest/ip/dns/static/")
+					if !existing[id] {
+						http.Error(w, `{"error":404,"message":"Not Found"}`, http.StatusNotFound)
+						return
+					}
+					delete(existing, id)
+					w.WriteHeader(http.StatusNoContent)
+
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+				BaseUrl:       server.URL,
+				Username:      mockUsername,
+				Password:      mockPassword,
+				SkipTLSVerify: true,
+			}, &MikrotikDefaults{})
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			if err := client.DeleteDNSRecords(context.Background(), tc.ids); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if removeCalls != tc.expectedRemoveCalls {
+				t.Errorf("Expected %d bulk removals, got %d", tc.expectedRemoveCalls, removeCalls)
+			}
+			if deleteCalls != tc.expectedDeleteCalls {
+				t.Errorf("Expected %d individual deletions, got %d", tc.expectedDeleteCalls, deleteCalls)
+			}
+			var remaining []string
+			for id := range existing {
+				remaining = append(remaining, id)
+			}
+			slices.Sort(remaining)
+			if !slices.Equal(remaining, tc.expectedRemaining) {
+				t.Errorf("Expected remaining records %v, got %v", tc.expectedRemaining, remaining)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package mikrotik
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrCircuitOpen is returned without contacting the router while its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open, router is unreachable")
+
+// circuitState is the state of a circuit breaker, as exported by the circuit_breaker_state metric
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitClosed:
+		return "closed"
+	case circuitHalfOpen:
+		return "half-open"
+	case circuitOpen:
+		return "open"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(s))
+	}
+}
+
+// circuitBreaker stops sending requests to a router after too many consecutive failures.
+// Once the cooldown elapsed, a single probe request is let through (half-open): the circuit closes
+// again if it succeeds, or stays open for another cooldown if it fails.
+// A nil circuitBreaker, or one with a threshold of 0, never opens.
+type circuitBreaker struct {
+	router    string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// newCircuitBreaker creates a closed circuit breaker for the given router
+func newCircuitBreaker(router string, threshold int, cooldown time.Duration) *circuitBreaker {
+	b := &circuitBreaker{
+		router:    router,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+	circuitBreakerStateGauge.WithLabelValues(router).Set(float64(circuitClosed))
+	return b
+}
+
+// allow returns an error wrapping ErrCircuitOpen if a request must not be sent to the router right now
+func (b *circuitBreaker) allow() error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		retryIn := b.cooldown - time.Since(b.openedAt)
+		if retryIn > 0 {
+			return fmt.Errorf("%w: %d consecutive failures, next attempt in %s", ErrCircuitOpen, b.failures, retryIn.Round(time.Second))
+		}
+		b.setState(circuitHalfOpen)
+		b.probing = true
+		return nil
+	case circuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w: waiting for the recovery probe to complete", ErrCircuitOpen)
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record updates the circuit with the outcome of a request let through by allow.
+// Only errors showing that the router is unreachable or unhealthy count as failures.
+func (b *circuitBreaker) record(err error) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil || !isTransientError(err) {
+		b.failures = 0
+		b.setState(circuitClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.setState(circuitOpen)
+	}
+}
+
+// abandon releases a request let through by allow without recording its outcome,
+// i.e. when it was cancelled by the caller rather than failed by the router
+func (b *circuitBreaker) abandon() {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// err returns an error wrapping ErrCircuitOpen if the circuit is currently open
+func (b *circuitBreaker) err() error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != circuitClosed {
+		return fmt.Errorf("%w: circuit is %s after %d consecutive failures", ErrCircuitOpen, b.state, b.failures)
+	}
+	return nil
+}
+
+// setState transitions the circuit, logging the change once rather than on every failed request.
+// The caller must hold the lock.
+func (b *circuitBreaker) setState(state circuitState) {
+	if b.state == state {
+		return
+	}
+
+	switch state {
+	case circuitOpen:
+		log.Errorf("circuit breaker for router %s is open after %d consecutive failures, failing fast for %s", b.router, b.failures, b.cooldown)
+	case circuitHalfOpen:
+		log.Infof("circuit breaker for router %s is half-open, probing whether the router recovered", b.router)
+	case circuitClosed:
+		log.Infof("circuit breaker for router %s is closed, the router recovered", b.router)
+	}
+
+	b.state = state
+	circuitBreakerStateGauge.WithLabelValues(b.router).Set(float64(state))
+}
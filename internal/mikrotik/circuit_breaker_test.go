@@ -0,0 +1,116 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	transientErr := &MikrotikAPIError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}
+	b := newCircuitBreaker("test", 2, time.Minute)
+	elapseCooldown := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.openedAt = b.openedAt.Add(-b.cooldown)
+	}
+
+	// Non-transient errors mean the router is alive, so they do not count
+	for range 3 {
+		if err := b.allow(); err != nil {
+			t.Fatalf("Expected closed circuit to allow requests, got %v", err)
+		}
+		b.record(&MikrotikAPIError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"})
+	}
+	if b.state != circuitClosed {
+		t.Fatalf("Expected circuit to stay closed on client errors, got %s", b.state)
+	}
+
+	// Consecutive transient failures open the circuit
+	for range 2 {
+		if err := b.allow(); err != nil {
+			t.Fatalf("Expected closed circuit to allow requests, got %v", err)
+		}
+		b.record(transientErr)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if err := b.err(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected open circuit to report ErrCircuitOpen, got %v", err)
+	}
+
+	// After the cooldown, a single probe is let through and a failure opens the circuit again
+	elapseCooldown()
+	if err := b.allow(); err != nil {
+		t.Fatalf("Expected probe to be allowed after cooldown, got %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected concurrent request to fail fast while probing, got %v", err)
+	}
+	b.record(transientErr)
+	if b.state != circuitOpen {
+		t.Fatalf("Expected failed probe to open the circuit, got %s", b.state)
+	}
+
+	// A successful probe closes the circuit
+	elapseCooldown()
+	if err := b.allow(); err != nil {
+		t.Fatalf("Expected probe to be allowed after cooldown, got %v", err)
+	}
+	b.record(nil)
+	if b.state != circuitClosed || b.err() != nil {
+		t.Errorf("Expected successful probe to close the circuit, got %s", b.state)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	for _, b := range []*circuitBreaker{nil, newCircuitBreaker("test", 0, time.Minute)} {
+		for range 10 {
+			b.record(context.DeadlineExceeded)
+		}
+		if err := b.allow(); err != nil {
+			t.Errorf("Expected disabled circuit breaker to allow requests, got %v", err)
+		}
+	}
+}
+
+func TestDoRequestCircuitBreaker(t *testing.T) {
+	hits := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrl:                 server.URL,
+		Username:                mockUsername,
+		Password:                mockPassword,
+		SkipTLSVerify:           true,
+		CircuitBreakerThreshold: 2,
+		CircuitBreakerCooldown:  time.Minute,
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	for range 2 {
+		if _, err := client.GetSystemInfo(context.Background()); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Expected request to reach the router and fail, got %v", err)
+		}
+	}
+	if err := client.Ready(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected client not to be ready, got %v", err)
+	}
+
+	if _, err := client.GetSystemInfo(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if hits != 2 {
+		t.Errorf("Expected the open circuit to fail fast without contacting the router, got %d requests", hits)
+	}
+}
@@ -0,0 +1,467 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// DNSClient is the set of RouterOS static DNS operations the provider relies on.
+// MikrotikApiClient talks to a real router, while MemoryDNSClient keeps the records in memory.
+// Every operation gives up as soon as its context is done.
+type DNSClient interface {
+	// String identifies the router in logs and errors
+	fmt.Stringer
+
+	// GetSystemInfo fetches information about the router
+	GetSystemInfo(ctx context.Context) (*MikrotikSystemInfo, error)
+
+	// GetDNSRecords fetches the static DNS records matching the filter
+	GetDNSRecords(ctx context.Context, filter DNSRecordFilter) ([]DNSRecord, error)
+
+	// QueryDNSRecords fetches the static DNS records matching any of the filters at once, without duplicates
+	QueryDNSRecords(ctx context.Context, filters []DNSRecordFilter) ([]DNSRecord, error)
+
+	// CreateDNSRecord adds a static DNS record as-is and returns it as stored by the router, including its ID.
+	// If the router refuses it because an identical record already exists, that record is taken over instead:
+	// its other fields are updated in place, and it is returned along with its previous state.
+	CreateDNSRecord(ctx context.Context, record *DNSRecord) (*DNSRecord, error)
+
+	// CreateDNSRecords adds several static DNS records at once and returns them in the same order.
+	// Records that could not be created are nil in the result, and reported as a *DNSRecordError each.
+	CreateDNSRecords(ctx context.Context, records []*DNSRecord) ([]*DNSRecord, error)
+
+	// UpdateDNSRecord sets the given fields of the static DNS record with the given ID, leaving the others
+	// untouched, and returns the record as stored by the router
+	UpdateDNSRecord(ctx context.Context, id string, fields map[string]string) (*DNSRecord, error)
+
+	// DeleteDNSRecord removes the static DNS record with the given ID.
+	// A record that does not exist anymore is not an error, as it is already deleted.
+	DeleteDNSRecord(ctx context.Context, id string) error
+
+	// DeleteDNSRecords removes the static DNS records with the given IDs.
+	// Records that do not exist anymore are not an error, as they are already deleted.
+	DeleteDNSRecords(ctx context.Context, ids []string) error
+}
+
+// DNSRecordError is the failure of a single record within a batch operation
+type DNSRecordError struct {
+	Record *DNSRecord
+	Err    error
+}
+
+func (e *DNSRecordError) Error() string {
+	return fmt.Sprintf("%s record %s: %v", e.Record.Type, recordName(e.Record), e.Err)
+}
+
+func (e *DNSRecordError) Unwrap() error {
+	return e.Err
+}
+
+// EndpointError is an endpoint that cannot be converted to DNS records
+type EndpointError struct {
+	Endpoint *endpoint.Endpoint
+	Err      error
+}
+
+func (e *EndpointError) Error() string {
+	return fmt.Sprintf("%s record %s: %v", e.Endpoint.RecordType, e.Endpoint.DNSName, e.Err)
+}
+
+func (e *EndpointError) Unwrap() error {
+	return e.Err
+}
+
+// DeleteConflictError is a record left in place because its fields changed since the endpoint deleting it was read
+type DeleteConflictError struct {
+	Record DNSRecord
+	// Fields lists the fields of the record that differ from the endpoint
+	Fields []string
+}
+
+func (e *DeleteConflictError) Error() string {
+	return fmt.Sprintf("%s record %s (%s) changed since it was read (%s), refusing to delete it",
+		e.Record.Type, recordName(&e.Record), e.Record.ID, strings.Join(e.Fields, ", "))
+}
+
+// readinessReporter is implemented by DNSClients that can tell whether their router is currently reachable
+type readinessReporter interface {
+	Ready() error
+}
+
+// deleteRecordsFromEndpoint deletes all DNS records associated with an endpoint. With checkConflicts,
+// records that changed since the endpoint was read are left in place and reported as conflicts.
+func deleteRecordsFromEndpoint(ctx context.Context, client DNSClient, ep *endpoint.Endpoint, checkConflicts bool, defaults *MikrotikDefaults) error {
+	log.Infof("deleting DNS records for endpoint: %+v", ep)
+
+	if len(ep.Targets) == 0 {
+		log.Warnf("no targets specified for endpoint %s, nothing to delete", ep.DNSName)
+		return nil
+	}
+
+	// The records are not needed beyond their IDs, unless they are compared with the endpoint
+	columns := targetColumns
+	if checkConflicts {
+		columns = allColumns
+	}
+	records, err := recordsForEndpoint(ctx, client, ep, columns)
+	if err != nil {
+		return err
+	}
+	var conflicts error
+	if checkConflicts {
+		records, conflicts = withoutDeleteConflicts(records, []*endpoint.Endpoint{ep}, defaults)
+	}
+
+	var ids []string
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	if len(ids) > 0 {
+		if err := client.DeleteDNSRecords(ctx, ids); err != nil {
+			log.Errorf("error deleting DNS records %s: %v", strings.Join(ids, ","), err)
+			return errors.Join(err, conflicts)
+		}
+	}
+
+	return conflicts
+}
+
+// withoutDeleteConflicts returns the records to delete that are still described exactly by one of the endpoints
+// deleting them, along with a *DeleteConflictError for each of the other ones
+func withoutDeleteConflicts(records []DNSRecord, endpoints []*endpoint.Endpoint, defaults *MikrotikDefaults) ([]DNSRecord, error) {
+	var kept []DNSRecord
+	var conflicts []error
+	for _, record := range records {
+		conflict := deleteConflict(record, endpoints, defaults)
+		if conflict == nil {
+			kept = append(kept, record)
+			continue
+		}
+
+		log.Warn(conflict)
+		deleteConflictsTotal.WithLabelValues(record.Type).Inc()
+		conflicts = append(conflicts, conflict)
+	}
+
+	if len(conflicts) > 0 {
+		return kept, fmt.Errorf("refused to delete %d records changed since they were read:\n%w", len(conflicts), errors.Join(conflicts...))
+	}
+	return kept, nil
+}
+
+// deleteConflict compares the current fields of a record with the endpoints deleting it, and returns a conflict
+// unless one of them still describes the record exactly, once the defaults it was created with are applied
+func deleteConflict(record DNSRecord, endpoints []*endpoint.Endpoint, defaults *MikrotikDefaults) *DeleteConflictError {
+	conflict := &DeleteConflictError{Record: record}
+	target, err := record.toExternalDNSTarget()
+	if err != nil {
+		conflict.Fields = []string{"target"}
+		return conflict
+	}
+
+	for _, ep := range endpoints {
+		if ep.DNSName != record.Name || ep.RecordType != record.Type || !slices.Contains(ep.Targets, target) {
+			continue
+		}
+		expected, err := NewDNSRecords(withTargets(ep, target))
+		if err != nil || len(expected) != 1 {
+			continue
+		}
+		applyDefaults(expected[0], defaults)
+
+		changed, err := record.changedFields(expected[0])
+		if err != nil {
+			continue
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+		conflict.Fields = slices.Sorted(maps.Keys(changed))
+	}
+	return conflict
+}
+
+// queryRecordsForEndpoints returns the DNS records matching any of the endpoints and their targets, without
+// duplicates. Every name, type and target is fetched by a single query, filtering them server-side.
+func queryRecordsForEndpoints(ctx context.Context, client DNSClient, endpoints []*endpoint.Endpoint) ([]DNSRecord, error) {
+	var filters []DNSRecordFilter
+	for _, ep := range endpoints {
+		if len(ep.Targets) == 0 {
+			log.Warnf("no targets specified for endpoint %s, nothing to delete", ep.DNSName)
+			continue
+		}
+		filters = append(filters, endpointFilters(ep, allColumns)...)
+	}
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	records, err := client.QueryDNSRecords(ctx, filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DNS records for %d endpoints: %w", len(endpoints), err)
+	}
+	return records, nil
+}
+
+// recordsForEndpoint returns the DNS records matching an endpoint and its targets, with the given columns.
+// The targets are filtered server-side.
+func recordsForEndpoint(ctx context.Context, client DNSClient, ep *endpoint.Endpoint, columns recordColumns) ([]DNSRecord, error) {
+	records, err := client.QueryDNSRecords(ctx, endpointFilters(ep, columns))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DNS records for %s::%s: %w", ep.RecordType, ep.DNSName, err)
+	}
+	return records, nil
+}
+
+// endpointFilters returns a filter for each target of the endpoint
+func endpointFilters(ep *endpoint.Endpoint, columns recordColumns) []DNSRecordFilter {
+	filters := make([]DNSRecordFilter, len(ep.Targets))
+	for i, target := range ep.Targets {
+		filters[i] = DNSRecordFilter{Name: ep.DNSName, Type: ep.RecordType, Target: target, Columns: columns}
+	}
+	return filters
+}
+
+// updateRecordsFromEndpoints updates in place the records of each old endpoint to match the new endpoint
+// at the same index, only sending the fields that changed. Both endpoints of a pair must have a single target.
+// Records that cannot be found are returned, so that they can be created instead.
+// Pairs with different names or types are updated by up to workers concurrent requests, and every update
+// applied is recorded in the journal, which may be nil.
+func updateRecordsFromEndpoints(ctx context.Context, client DNSClient, j *journal, workers int, defaults *MikrotikDefaults, oldEndpoints, newEndpoints []*endpoint.Endpoint) ([]*DNSRecord, error) {
+	missing := make([]*DNSRecord, len(oldEndpoints))
+	errs := forEachInGroups(workers, oldEndpoints, endpointKey, func(i int, oldEndpoint *endpoint.Endpoint) error {
+		desired, err := newRecordsFromEndpoints([]*endpoint.Endpoint{newEndpoints[i]}, defaults)
+		if err != nil {
+			return err
+		}
+		if len(desired) != 1 {
+			return fmt.Errorf("expected a single target to update %s::%s, got %d", oldEndpoint.RecordType, oldEndpoint.DNSName, len(desired))
+		}
+
+		existing, err := recordsForEndpoint(ctx, client, oldEndpoint, allColumns)
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			log.Warnf("no DNS record to update found for endpoint %+v, creating it instead", oldEndpoint)
+			missing[i] = desired[0]
+			return nil
+		}
+
+		var errs []error
+		for _, record := range existing {
+			fields, err := record.changedFields(desired[0])
+			if err != nil {
+				return err
+			}
+			if len(fields) == 0 {
+				log.Debugf("DNS record %s is already up to date", record.ID)
+				continue
+			}
+
+			log.Infof("updating DNS record %s (%s::%s) with %v", record.ID, record.Type, record.Name, fields)
+			updated, err := client.UpdateDNSRecord(ctx, record.ID, fields)
+			if err == nil {
+				err = j.updated(record, updated)
+			}
+			if err != nil {
+				errs = append(errs, &DNSRecordError{Record: &record, Err: err})
+			}
+		}
+		return errors.Join(errs...)
+	})
+
+	return slices.DeleteFunc(missing, func(record *DNSRecord) bool { return record == nil }), errors.Join(errs...)
+}
+
+// findIdenticalRecord returns the record on the router with the same name or regexp, type and target as the
+// given one, or nil if there is none
+func findIdenticalRecord(ctx context.Context, client DNSClient, record *DNSRecord) (*DNSRecord, error) {
+	existing, err := client.GetDNSRecords(ctx, DNSRecordFilter{Name: record.Name, Type: record.Type})
+	if err != nil {
+		return nil, err
+	}
+	if i := slices.IndexFunc(existing, record.sameAs); i >= 0 {
+		return &existing[i], nil
+	}
+	return nil, nil
+}
+
+// skipExistingRecords returns the records that do not exist on the router yet. A record identical to one of
+// them, such as one left behind by an earlier attempt that timed out, is not created again: it is taken over
+// instead, updating in place the fields that differ. Updates are recorded in the journal, which may be nil.
+func skipExistingRecords(ctx context.Context, client DNSClient, j *journal, records []*DNSRecord) ([]*DNSRecord, error) {
+	existing := map[DNSRecordFilter][]DNSRecord{}
+	var missing []*DNSRecord
+	for _, record := range records {
+		filter := DNSRecordFilter{Name: record.Name, Type: record.Type}
+		if _, ok := existing[filter]; !ok {
+			found, err := client.GetDNSRecords(ctx, filter)
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up existing DNS records: %w", err)
+			}
+			existing[filter] = found
+		}
+
+		i := slices.IndexFunc(existing[filter], record.sameAs)
+		if i < 0 {
+			missing = append(missing, record)
+			continue
+		}
+		match := existing[filter][i]
+		// Do not take over the same record twice
+		existing[filter] = slices.Concat(existing[filter][:i], existing[filter][i+1:])
+
+		takenOver, err := takeOverRecord(ctx, client, match, record)
+		if err == nil {
+			err = j.updated(match, takenOver)
+		}
+		if err != nil {
+			return nil, &DNSRecordError{Record: record, Err: err}
+		}
+	}
+
+	return missing, nil
+}
+
+// takeOverRecord takes over an existing record identical to the one to create, updating in place the fields that
+// differ. The record is returned along with its previous state, so that it is journaled as an update.
+func takeOverRecord(ctx context.Context, client DNSClient, existing DNSRecord, record *DNSRecord) (*DNSRecord, error) {
+	fields, err := existing.changedFields(record)
+	if err != nil {
+		return nil, err
+	}
+
+	takenOver := existing
+	if len(fields) == 0 {
+		log.Infof("DNS record %s (%s::%s) already exists, not creating it again", existing.ID, existing.Type, recordName(&existing))
+	} else {
+		log.Infof("DNS record %s (%s::%s) already exists, updating it with %v instead of creating it again", existing.ID, existing.Type, recordName(&existing), fields)
+		updated, err := client.UpdateDNSRecord(ctx, existing.ID, fields)
+		if err != nil {
+			return nil, err
+		}
+		takenOver = *updated
+	}
+	takenOver.takenOver = &existing
+	return &takenOver, nil
+}
+
+// deleteDNSRecordsOneByOne removes records with one call per record, ignoring the ones that are already gone.
+// Every record is attempted, even if some of them fail.
+func deleteDNSRecordsOneByOne(ctx context.Context, client DNSClient, ids []string) error {
+	var errs []error
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("record %s: %w", id, err))
+			continue
+		}
+
+		err := client.DeleteDNSRecord(ctx, id)
+		if IsNotFound(err) {
+			log.Debugf("DNS record %s is already gone", id)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("record %s: %w", id, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// createRecordsFromEndpoint creates one DNS record per endpoint target, enforcing the configured defaults
+func createRecordsFromEndpoint(ctx context.Context, client DNSClient, defaults *MikrotikDefaults, ep *endpoint.Endpoint) ([]*DNSRecord, error) {
+	log.Infof("creating DNS records for endpoint: %+v", ep)
+
+	if len(ep.Targets) == 0 {
+		log.Warnf("no targets specified for endpoint %s, nothing to delete", ep.DNSName)
+		return nil, nil
+	}
+
+	// Convert endpoint to multiple DNS records
+	records, err := newRecordsFromEndpoints([]*endpoint.Endpoint{ep}, defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	createdRecords, err := client.CreateDNSRecords(ctx, records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DNS record: %w", err)
+	}
+
+	log.Infof("successfully created %d DNS records", len(createdRecords))
+	return createdRecords, nil
+}
+
+// newRecordsFromEndpoints converts endpoints to the DNS records to create, enforcing the configured defaults
+func newRecordsFromEndpoints(endpoints []*endpoint.Endpoint, defaults *MikrotikDefaults) ([]*DNSRecord, error) {
+	var records []*DNSRecord
+	for _, ep := range endpoints {
+		if len(ep.Targets) == 0 {
+			log.Warnf("no targets specified for endpoint %s, nothing to create", ep.DNSName)
+			continue
+		}
+
+		epRecords, err := NewDNSRecords(ep)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert endpoint to DNS records: %w", err)
+		}
+		for _, record := range epRecords {
+			applyDefaults(record, defaults)
+		}
+		records = append(records, epRecords...)
+	}
+
+	return records, nil
+}
+
+// createDNSRecordsOneByOne creates records with one call per record, for clients without a bulk mechanism.
+// Every record is attempted, even if some of them fail.
+func createDNSRecordsOneByOne(ctx context.Context, client DNSClient, records []*DNSRecord) ([]*DNSRecord, error) {
+	created := make([]*DNSRecord, len(records))
+	var errs []error
+	for i, record := range records {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, &DNSRecordError{Record: record, Err: err})
+			continue
+		}
+
+		var err error
+		if created[i], err = client.CreateDNSRecord(ctx, record); err != nil {
+			errs = append(errs, &DNSRecordError{Record: record, Err: err})
+		}
+	}
+
+	return created, errors.Join(errs...)
+}
+
+// applyDefaults sets the default TTL and comment on a record about to be created
+func applyDefaults(record *DNSRecord, defaults *MikrotikDefaults) {
+	if defaults == nil {
+		return
+	}
+
+	// Enforce Default TTL
+	if record.TTL == "0s" && defaults.DefaultTTL > 0 {
+		log.Debugf("Setting default TTL for created record: %+v", record)
+		record.TTL, _ = EndpointTTLtoMikrotikTTL(endpoint.TTL(defaults.DefaultTTL))
+	}
+
+	// Enforce Default Comment
+	if defaults.DefaultComment != "" {
+		if record.Comment != "" {
+			log.Debugf("Record already has a comment, skipping default comment: %+v", record)
+		} else {
+			log.Debugf("Setting default comment for created record: %+v", record)
+			record.Comment = defaults.DefaultComment
+		}
+	}
+}
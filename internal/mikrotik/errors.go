@@ -0,0 +1,87 @@
+package mikrotik
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MikrotikAPIError is returned when the router answers a request with a non-2xx status
+type MikrotikAPIError struct {
+	// StatusCode and Status are the HTTP status of the response, i.e. 400 and "400 Bad Request"
+	StatusCode int    `json:"-"`
+	Status     string `json:"-"`
+
+	// Code, Message and Detail are the error reported by RouterOS, i.e. 400, "Bad Request" and "no such item"
+	Code    int    `json:"error"`
+	Message string `json:"message"`
+	Detail  string `json:"detail"`
+}
+
+// newMikrotikAPIError creates a MikrotikAPIError from a failed response and its body
+func newMikrotikAPIError(resp *http.Response, body []byte) *MikrotikAPIError {
+	apiErr := &MikrotikAPIError{}
+	if err := json.Unmarshal(body, apiErr); err != nil {
+		log.Debugf("failed to parse the error returned by the router: %v", err)
+	}
+	apiErr.StatusCode = resp.StatusCode
+	apiErr.Status = resp.Status
+	return apiErr
+}
+
+func (e *MikrotikAPIError) Error() string {
+	if reason := e.reason(); reason != "" {
+		return fmt.Sprintf("request failed: %s: %s", e.Status, reason)
+	}
+	return fmt.Sprintf("request failed: %s", e.Status)
+}
+
+// HTTPStatus returns the HTTP status code the router answered with
+func (e *MikrotikAPIError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// reason returns the most specific explanation reported by the router, if any
+func (e *MikrotikAPIError) reason() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	if e.Message != http.StatusText(e.StatusCode) {
+		return e.Message
+	}
+	return ""
+}
+
+// IsNotFound reports whether the router failed a request because the item does not exist
+func IsNotFound(err error) bool {
+	var apiErr *MikrotikAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusNotFound || strings.Contains(apiErr.reason(), "no such item")
+}
+
+// IsAlreadyExists reports whether the router failed a request because an identical item already exists
+func IsAlreadyExists(err error) bool {
+	var apiErr *MikrotikAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusConflict || strings.Contains(apiErr.reason(), "already exists")
+}
+
+// IsPermissionDenied reports whether the router failed a request because the user is not allowed to make it,
+// either because of invalid credentials or because its group lacks the required policies
+func IsPermissionDenied(err error) bool {
+	var apiErr *MikrotikAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	reason := apiErr.reason()
+	return apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden ||
+		strings.Contains(reason, "not enough permissions") || strings.Contains(reason, "permission denied")
+}
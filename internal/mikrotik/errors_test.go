@@ -0,0 +1,92 @@
+package mikrotik
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestMikrotikAPIError(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		status                int
+		body                  string
+		expectedError         string
+		expectedNotFound      bool
+		expectedAlreadyExists bool
+		expectedPermission    bool
+	}{
+		{
+			name:             "no such item",
+			status:           http.StatusBadRequest,
+			body:             `{"error":400,"message":"Bad Request","detail":"no such item"}`,
+			expectedError:    "request failed: 400 Bad Request: no such item",
+			expectedNotFound: true,
+		},
+		{
+			name:             "not found status",
+			status:           http.StatusNotFound,
+			body:             `{"error":404,"message":"Not Found"}`,
+			expectedError:    "request failed: 404 Not Found",
+			expectedNotFound: true,
+		},
+		{
+			name:                  "entry already exists",
+			status:                http.StatusBadRequest,
+			body:                  `{"error":400,"message":"Bad Request","detail":"failure: entry already exists"}`,
+			expectedError:         "request failed: 400 Bad Request: failure: entry already exists",
+			expectedAlreadyExists: true,
+		},
+		{
+			name:               "missing policy",
+			status:             http.StatusBadRequest,
+			body:               `{"error":400,"message":"Bad Request","detail":"not enough permissions (9)"}`,
+			expectedError:      "request failed: 400 Bad Request: not enough permissions (9)",
+			expectedPermission: true,
+		},
+		{
+			name:               "invalid credentials",
+			status:             http.StatusUnauthorized,
+			body:               `{"error":401,"message":"Unauthorized"}`,
+			expectedError:      "request failed: 401 Unauthorized",
+			expectedPermission: true,
+		},
+		{
+			name:          "message without detail",
+			status:        http.StatusBadRequest,
+			body:          `{"error":400,"message":"unknown parameter"}`,
+			expectedError: "request failed: 400 Bad Request: unknown parameter",
+		},
+		{
+			name:          "body that is not JSON",
+			status:        http.StatusBadGateway,
+			body:          `<html>Bad Gateway</html>`,
+			expectedError: "request failed: 502 Bad Gateway",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tc.status, Status: fmt.Sprintf("%d %s", tc.status, http.StatusText(tc.status))}
+			err := fmt.Errorf("error deleting DNS record: %w", newMikrotikAPIError(resp, []byte(tc.body)))
+
+			var apiErr *MikrotikAPIError
+			if !errors.As(err, &apiErr) || apiErr.HTTPStatus() != tc.status {
+				t.Fatalf("Expected a MikrotikAPIError with status %d, got %v", tc.status, err)
+			}
+			if apiErr.Error() != tc.expectedError {
+				t.Errorf("Expected error %q, got %q", tc.expectedError, apiErr.Error())
+			}
+			if IsNotFound(err) != tc.expectedNotFound {
+				t.Errorf("Expected IsNotFound to be %v", tc.expectedNotFound)
+			}
+			if IsAlreadyExists(err) != tc.expectedAlreadyExists {
+				t.Errorf("Expected IsAlreadyExists to be %v", tc.expectedAlreadyExists)
+			}
+			if IsPermissionDenied(err) != tc.expectedPermission {
+				t.Errorf("Expected IsPermissionDenied to be %v", tc.expectedPermission)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// journal records the mutations applied to a router, so that they can be undone in reverse order
+// if a later one fails. Mutations may be recorded concurrently. A nil journal records nothing.
+type journal struct {
+	client DNSClient
+
+	mu      sync.Mutex
+	entries []journalEntry
+}
+
+// journalEntry is a single mutation, along with the way to undo it
+type journalEntry struct {
+	description string
+	undo        func(ctx context.Context) error
+}
+
+// newJournal creates an empty journal for the given router
+func newJournal(client DNSClient) *journal {
+	return &journal{client: client}
+}
+
+// add appends an entry to the journal
+func (j *journal) add(entry journalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, entry)
+}
+
+// created records the creation of a record, undone by deleting it. A record taken over instead of being created
+// existed before, so it is recorded as an update.
+func (j *journal) created(record *DNSRecord) {
+	if j == nil || record == nil || record.ID == "" {
+		return
+	}
+	if record.takenOver != nil {
+		if err := j.updated(*record.takenOver, record); err != nil {
+			log.Errorf("failed to journal the takeover of record %s: %v", record.ID, err)
+		}
+		return
+	}
+
+	id := record.ID
+	j.add(journalEntry{
+		description: fmt.Sprintf("creation of %s record %s (%s)", record.Type, recordName(record), id),
+		undo: func(ctx context.Context) error {
+			err := j.client.DeleteDNSRecord(ctx, id)
+			if IsNotFound(err) {
+				return nil
+			}
+			return err
+		},
+	})
+}
+
+// deleted records the deletion of a record, undone by creating it again. The recreated record gets a new ID.
+func (j *journal) deleted(record DNSRecord) {
+	if j == nil {
+		return
+	}
+
+	deleted := record
+	deleted.ID = ""
+	j.add(journalEntry{
+		description: fmt.Sprintf("deletion of %s record %s (%s)", record.Type, recordName(&record), record.ID),
+		undo: func(ctx context.Context) error {
+			_, err := j.client.CreateDNSRecord(ctx, &deleted)
+			return err
+		},
+	})
+}
+
+// updated records the update of a record from before to after, undone by setting the changed fields back
+func (j *journal) updated(before DNSRecord, after *DNSRecord) error {
+	if j == nil || after == nil {
+		return nil
+	}
+
+	fields, err := after.changedFields(&before)
+	if err != nil {
+		return fmt.Errorf("failed to compute how to undo the update of record %s: %w", before.ID, err)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	j.add(journalEntry{
+		description: fmt.Sprintf("update of %s record %s (%s)", before.Type, recordName(&before), before.ID),
+		undo: func(ctx context.Context) error {
+			_, err := j.client.UpdateDNSRecord(ctx, before.ID, fields)
+			return err
+		},
+	})
+	return nil
+}
+
+// deletedUnlessPresent records the deletion of the records that do not exist on the router anymore,
+// for when a bulk deletion failed part way through
+func (j *journal) deletedUnlessPresent(ctx context.Context, records []DNSRecord) error {
+	if j == nil {
+		return nil
+	}
+
+	remaining := map[DNSRecordFilter][]DNSRecord{}
+	for _, record := range records {
+		filter := DNSRecordFilter{Name: record.Name, Type: record.Type, Columns: targetColumns}
+		if _, ok := remaining[filter]; !ok {
+			existing, err := j.client.GetDNSRecords(ctx, filter)
+			if err != nil {
+				return fmt.Errorf("failed to check which records were deleted: %w", err)
+			}
+			remaining[filter] = existing
+		}
+
+		if !slices.ContainsFunc(remaining[filter], func(r DNSRecord) bool { return r.ID == record.ID }) {
+			j.deleted(record)
+		}
+	}
+	return nil
+}
+
+// rollback undoes every recorded mutation in reverse order. Every mutation is attempted, even if some fail.
+// The context is not cancelled along with the caller's, as stopping half way would defeat the purpose.
+func (j *journal) rollback(ctx context.Context) error {
+	if j == nil || len(j.entries) == 0 {
+		return nil
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	log.Warnf("rolling back %d changes applied to router %s", len(j.entries), j.client)
+
+	var errs []error
+	for _, entry := range slices.Backward(j.entries) {
+		log.Infof("rolling back %s", entry.description)
+		if err := entry.undo(ctx); err != nil {
+			log.Errorf("failed to roll back %s: %v", entry.description, err)
+			errs = append(errs, fmt.Errorf("%s: %w", entry.description, err))
+		}
+	}
+	j.entries = nil
+
+	return errors.Join(errs...)
+}
+
+// recordName returns the name of a record, or its regexp if it has none
+func recordName(record *DNSRecord) string {
+	if record.Name == "" {
+		return record.Regexp
+	}
+	return record.Name
+}
@@ -0,0 +1,100 @@
+package mikrotik
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// failingCreateClient fails to create records whose name starts with "bad", and optionally to delete any record
+type failingCreateClient struct {
+	*MemoryDNSClient
+	failDelete bool
+}
+
+func (c *failingCreateClient) CreateDNSRecord(ctx context.Context, record *DNSRecord) (*DNSRecord, error) {
+	if strings.HasPrefix(record.Name, "bad") {
+		return nil, fmt.Errorf("failure: invalid value")
+	}
+	return c.MemoryDNSClient.CreateDNSRecord(ctx, record)
+}
+
+func (c *failingCreateClient) CreateDNSRecords(ctx context.Context, records []*DNSRecord) ([]*DNSRecord, error) {
+	return createDNSRecordsOneByOne(ctx, c, records)
+}
+
+func (c *failingCreateClient) DeleteDNSRecord(ctx context.Context, id string) error {
+	if c.failDelete {
+		return fmt.Errorf("failure: router is read-only")
+	}
+	return c.MemoryDNSClient.DeleteDNSRecord(ctx, id)
+}
+
+func TestMikrotikProvider_ApplyChanges_Rollback(t *testing.T) {
+	testCases := []struct {
+		name            string
+		failDelete      bool
+		expectedError   string
+		expectedRecords []string
+	}{
+		{
+			name:            "applied changes are undone",
+			expectedError:   "changes rolled back",
+			expectedRecords: []string{"delete.example.com=2.2.2.2", "update.example.com=1.1.1.1 (old)"},
+		},
+		{
+			name:            "failed rollback is reported",
+			failDelete:      true,
+			expectedError:   "rollback failed",
+			expectedRecords: []string{"delete.example.com=2.2.2.2", "good.example.com=3.3.3.3", "update.example.com=1.1.1.1 (old)"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &failingCreateClient{
+				MemoryDNSClient: NewMemoryDNSClient(
+					DNSRecord{Name: "update.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h", Comment: "old"},
+					DNSRecord{Name: "delete.example.com", Type: "A", Address: "2.2.2.2", TTL: "1h"},
+				),
+				failDelete: tc.failDelete,
+			}
+
+			p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{DefaultTTL: 3600}, client)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			err = p.ApplyChanges(context.Background(), &plan.Changes{
+				Create: []*endpoint.Endpoint{
+					NewEndpoint("good.example.com", []string{"3.3.3.3"}, "A", 3600, nil),
+					NewEndpoint("bad.example.com", []string{"4.4.4.4"}, "A", 3600, nil),
+				},
+				UpdateOld: []*endpoint.Endpoint{NewEndpoint("update.example.com", []string{"1.1.1.1"}, "A", 3600, []map[string]string{{"comment": "old"}})},
+				UpdateNew: []*endpoint.Endpoint{NewEndpoint("update.example.com", []string{"1.1.1.1"}, "A", 3600, []map[string]string{{"comment": "new"}})},
+				Delete:    []*endpoint.Endpoint{NewEndpoint("delete.example.com", []string{"2.2.2.2"}, "A", 3600, nil)},
+			})
+			if err == nil || !strings.Contains(err.Error(), "bad.example.com") || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Fatalf("Expected an error about bad.example.com containing %q, got %v", tc.expectedError, err)
+			}
+
+			var records []string
+			for _, record := range client.Records() {
+				description := record.Name + "=" + record.Address
+				if record.Comment != "" {
+					description += " (" + record.Comment + ")"
+				}
+				records = append(records, description)
+			}
+			slices.Sort(records)
+			if !slices.Equal(records, tc.expectedRecords) {
+				t.Errorf("Expected records %v, got %v", tc.expectedRecords, records)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package mikrotik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MemoryDNSClient is a DNSClient keeping static DNS records in memory, mimicking how RouterOS stores them.
+// It is meant for testing code built on top of the provider without a router. As with a router, operations
+// fail without touching the records once their context is done.
+type MemoryDNSClient struct {
+	// SystemInfo is returned by GetSystemInfo
+	SystemInfo MikrotikSystemInfo
+
+	mu      sync.Mutex
+	records []DNSRecord
+	nextID  int
+}
+
+// NewMemoryDNSClient creates a new in-memory client holding the given records.
+// Records without an ID are assigned one, the way RouterOS does (i.e. *1, *2, ..., *1A).
+func NewMemoryDNSClient(records ...DNSRecord) *MemoryDNSClient {
+	c := &MemoryDNSClient{
+		SystemInfo: MikrotikSystemInfo{BoardName: "memory", Platform: "MikroTik", Version: "7.16 (stable)"},
+		nextID:     1,
+	}
+	for _, record := range records {
+		if record.ID == "" {
+			record.ID = c.newID()
+		}
+		c.records = append(c.records, record)
+	}
+	return c
+}
+
+// GetSystemInfo returns the configured system information
+func (c *MemoryDNSClient) GetSystemInfo(ctx context.Context) (*MikrotikSystemInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	info := c.SystemInfo
+	return &info, nil
+}
+
+// GetDNSRecords returns the records matching the filter. As with the RouterOS API, the type filter
+// accepts a comma-separated list and defaults to the managed record types.
+func (c *MemoryDNSClient) GetDNSRecords(ctx context.Context, filter DNSRecordFilter) ([]DNSRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records := []DNSRecord{}
+	for _, record := range c.records {
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+	}
+
+	log.Debugf("fetched %d DNS records from memory", len(records))
+	return records, nil
+}
+
+// QueryDNSRecords returns copies of the stored records matching any of the filters
+func (c *MemoryDNSClient) QueryDNSRecords(ctx context.Context, filters []DNSRecordFilter) ([]DNSRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records := filterRecords(c.records, filters...)
+	log.Debugf("fetched %d DNS records from memory", len(records))
+	return records, nil
+}
+
+// CreateDNSRecord stores a copy of the record under a new ID
+func (c *MemoryDNSClient) CreateDNSRecord(ctx context.Context, record *DNSRecord) (*DNSRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if record.Name == "" && record.Regexp == "" {
+		return nil, fmt.Errorf("failure: name or regexp must be set")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	created := *record
+	created.ID = c.newID()
+	if created.Type == "" {
+		created.Type = "A"
+	}
+	if created.TTL == "" {
+		created.TTL = "1d"
+	}
+	c.records = append(c.records, created)
+
+	log.Debugf("created record in memory: %+v", created)
+	return &created, nil
+}
+
+// CreateDNSRecords stores a copy of each record, one at a time. Once the context is done, the remaining
+// records are reported as failed.
+func (c *MemoryDNSClient) CreateDNSRecords(ctx context.Context, records []*DNSRecord) ([]*DNSRecord, error) {
+	return createDNSRecordsOneByOne(ctx, c, records)
+}
+
+// UpdateDNSRecord sets the given fields of the record with the given ID
+func (c *MemoryDNSClient) UpdateDNSRecord(ctx context.Context, id string, fields map[string]string) (*DNSRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, record := range c.records {
+		if record.ID != id {
+			continue
+		}
+
+		current, err := record.fields()
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(current, fields)
+		current[".id"] = id
+
+		jsonRecord, err := json.Marshal(current)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling DNS record: %w", err)
+		}
+		var updated DNSRecord
+		if err := json.Unmarshal(jsonRecord, &updated); err != nil {
+			return nil, fmt.Errorf("error unmarshalling DNS record: %w", err)
+		}
+		c.records[i] = updated
+
+		log.Debugf("updated record in memory: %+v", updated)
+		return &updated, nil
+	}
+
+	// The router answers the same way when the record does not exist
+	return nil, &MikrotikAPIError{StatusCode: http.StatusNotFound, Status: "404 Not Found", Code: http.StatusNotFound, Message: "Not Found", Detail: "no such item"}
+}
+
+// DeleteDNSRecord removes the record with the given ID
+func (c *MemoryDNSClient) DeleteDNSRecord(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, record := range c.records {
+		if record.ID == id {
+			c.records = slices.Delete(c.records, i, i+1)
+			log.Debugf("deleted record from memory: %s", id)
+			return nil
+		}
+	}
+
+	log.Debugf("record %s is not in memory, nothing to delete", id)
+	return nil
+}
+
+// DeleteDNSRecords removes the records with the given IDs, ignoring the ones that do not exist
+func (c *MemoryDNSClient) DeleteDNSRecords(ctx context.Context, ids []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.records = slices.DeleteFunc(c.records, func(record DNSRecord) bool {
+		return slices.Contains(ids, record.ID)
+	})
+	log.Debugf("deleted records from memory: %s", strings.Join(ids, ","))
+
+	return nil
+}
+
+// Records returns a copy of every stored record, regardless of its type
+func (c *MemoryDNSClient) Records() []DNSRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.records)
+}
+
+// String identifies the in-memory router
+func (c *MemoryDNSClient) String() string {
+	return "memory"
+}
+
+// newID returns the next RouterOS-style ID. Callers must hold the lock, if any.
+func (c *MemoryDNSClient) newID() string {
+	id := fmt.Sprintf("*%X", c.nextID)
+	c.nextID++
+	return id
+}
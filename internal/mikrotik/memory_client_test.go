@@ -0,0 +1,183 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestMemoryDNSClient_IDs(t *testing.T) {
+	client := NewMemoryDNSClient(
+		DNSRecord{Name: "a.example.com", Type: "A", Address: "1.1.1.1"},
+		DNSRecord{ID: "*9", Name: "b.example.com", Type: "A", Address: "2.2.2.2"},
+	)
+
+	records := client.Records()
+	if records[0].ID != "*1" {
+		t.Errorf("Expected a generated ID *1, got %s", records[0].ID)
+	}
+	if records[1].ID != "*9" {
+		t.Errorf("Expected the provided ID *9 to be kept, got %s", records[1].ID)
+	}
+
+	// RouterOS IDs are hexadecimal
+	for range 24 {
+		if _, err := client.CreateDNSRecord(context.Background(), &DNSRecord{Name: "c.example.com", Type: "A", Address: "3.3.3.3"}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	created, err := client.CreateDNSRecord(context.Background(), &DNSRecord{Name: "d.example.com", Type: "A", Address: "4.4.4.4"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if created.ID != "*1A" {
+		t.Errorf("Expected ID *1A, got %s", created.ID)
+	}
+	if created.TTL != "1d" {
+		t.Errorf("Expected the RouterOS default TTL of 1d, got %s", created.TTL)
+	}
+}
+
+func TestMemoryDNSClient_GetDNSRecords(t *testing.T) {
+	client := NewMemoryDNSClient(
+		DNSRecord{Name: "example.com", Type: "A", Address: "1.1.1.1"},
+		DNSRecord{Name: "example.com", Type: "AAAA", Address: "::1"},
+		DNSRecord{Name: "www.example.com", Type: "CNAME", CName: "example.com"},
+		DNSRecord{Name: "example.com", Type: "FWD"},
+	)
+
+	tests := []struct {
+		name          string
+		filter        DNSRecordFilter
+		expectedCount int
+	}{
+		{name: "All managed types", filter: DNSRecordFilter{}, expectedCount: 3},
+		{name: "By name", filter: DNSRecordFilter{Name: "example.com"}, expectedCount: 2},
+		{name: "By type", filter: DNSRecordFilter{Type: "CNAME"}, expectedCount: 1},
+		{name: "By name and type list", filter: DNSRecordFilter{Name: "example.com", Type: "A,AAAA"}, expectedCount: 2},
+		{name: "Unmanaged type requested explicitly", filter: DNSRecordFilter{Type: "FWD"}, expectedCount: 1},
+		{name: "No match", filter: DNSRecordFilter{Name: "missing.example.com"}, expectedCount: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := client.GetDNSRecords(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(records) != tt.expectedCount {
+				t.Errorf("Expected %d records, got %d: %+v", tt.expectedCount, len(records), records)
+			}
+		})
+	}
+}
+
+func TestMemoryDNSClient_DeleteDNSRecord(t *testing.T) {
+	client := NewMemoryDNSClient(DNSRecord{Name: "example.com", Type: "A", Address: "1.1.1.1"})
+
+	if err := client.DeleteDNSRecord(context.Background(), "*1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(client.Records()) != 0 {
+		t.Errorf("Expected no records left, got %+v", client.Records())
+	}
+	if err := client.DeleteDNSRecord(context.Background(), "*1"); err != nil {
+		t.Errorf("Expected deleting a missing record to succeed, got %v", err)
+	}
+}
+
+func TestMemoryDNSClient_UpdateDNSRecord(t *testing.T) {
+	client := NewMemoryDNSClient(DNSRecord{Name: "example.com", Type: "A", Address: "1.1.1.1"})
+
+	updated, err := client.UpdateDNSRecord(context.Background(), "*1", map[string]string{"address": "2.2.2.2"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if updated.Address != "2.2.2.2" || client.Records()[0].Address != "2.2.2.2" {
+		t.Errorf("Expected the address to be updated, got %+v", client.Records())
+	}
+
+	if _, err := client.UpdateDNSRecord(context.Background(), "*2", map[string]string{"address": "2.2.2.2"}); !IsNotFound(err) {
+		t.Errorf("Expected updating a missing record to fail as not found, got %v", err)
+	}
+}
+
+func TestMemoryDNSClient_Cancellation(t *testing.T) {
+	record := DNSRecord{Name: "example.com", Type: "A", Address: "1.1.1.1"}
+	tests := []struct {
+		name string
+		call func(ctx context.Context, client *MemoryDNSClient) error
+	}{
+		{
+			name: "GetSystemInfo",
+			call: func(ctx context.Context, client *MemoryDNSClient) error {
+				_, err := client.GetSystemInfo(ctx)
+				return err
+			},
+		},
+		{
+			name: "GetDNSRecords",
+			call: func(ctx context.Context, client *MemoryDNSClient) error {
+				_, err := client.GetDNSRecords(ctx, DNSRecordFilter{})
+				return err
+			},
+		},
+		{
+			name: "QueryDNSRecords",
+			call: func(ctx context.Context, client *MemoryDNSClient) error {
+				_, err := client.QueryDNSRecords(ctx, []DNSRecordFilter{{Name: "example.com"}})
+				return err
+			},
+		},
+		{
+			name: "CreateDNSRecord",
+			call: func(ctx context.Context, client *MemoryDNSClient) error {
+				_, err := client.CreateDNSRecord(ctx, &DNSRecord{Name: "new.example.com", Type: "A", Address: "2.2.2.2"})
+				return err
+			},
+		},
+		{
+			name: "CreateDNSRecords",
+			call: func(ctx context.Context, client *MemoryDNSClient) error {
+				_, err := client.CreateDNSRecords(ctx, []*DNSRecord{{Name: "new.example.com", Type: "A", Address: "2.2.2.2"}})
+				return err
+			},
+		},
+		{
+			name: "UpdateDNSRecord",
+			call: func(ctx context.Context, client *MemoryDNSClient) error {
+				_, err := client.UpdateDNSRecord(ctx, "*1", map[string]string{"address": "2.2.2.2"})
+				return err
+			},
+		},
+		{
+			name: "DeleteDNSRecord",
+			call: func(ctx context.Context, client *MemoryDNSClient) error {
+				return client.DeleteDNSRecord(ctx, "*1")
+			},
+		},
+		{
+			name: "DeleteDNSRecords",
+			call: func(ctx context.Context, client *MemoryDNSClient) error {
+				return client.DeleteDNSRecords(ctx, []string{"*1"})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewMemoryDNSClient(record)
+			before := client.Records()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := tt.call(ctx, client); !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected context.Canceled, got %v", err)
+			}
+			if !slices.Equal(client.Records(), before) {
+				t.Errorf("Expected the records to be left untouched, got %+v", client.Records())
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package mikrotik
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "external_dns_mikrotik"
+
+var (
+	// activeEndpointGauge reports which of the candidate base URLs of a router is currently in use
+	activeEndpointGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "active_endpoint",
+		Help:      "Set to 1 for the base URL currently used to reach a router, 0 for the other candidates.",
+	}, []string{"router", "endpoint"})
+
+	// endpointFailoversTotal counts switches between the candidate base URLs of a router
+	endpointFailoversTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "endpoint_failovers_total",
+		Help:      "Number of times the client switched to a different base URL of a router.",
+	}, []string{"router", "from", "to"})
+
+	// requestRetriesTotal counts requests sent again after a transient failure
+	requestRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "request_retries_total",
+		Help:      "Number of RouterOS API requests retried after a transient failure.",
+	}, []string{"router", "method"})
+
+	// circuitBreakerStateGauge reports the state of the circuit breaker of each router
+	circuitBreakerStateGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "circuit_breaker_state",
+		Help:      "State of the circuit breaker of a router: 0 (closed), 1 (half-open, probing) or 2 (open, router unreachable).",
+	}, []string{"router"})
+
+	// requestsInFlightGauge reports the number of requests currently sent to each router
+	requestsInFlightGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "requests_in_flight",
+		Help:      "Number of RouterOS API requests currently in flight.",
+	}, []string{"router"})
+
+	// throttleWaitSeconds observes how long requests waited for the rate limit and the in-flight limit
+	throttleWaitSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "throttle_wait_seconds",
+		Help:      "Time RouterOS API requests spent waiting for the rate limit and the in-flight limit.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"router"})
+
+	// skippedEndpointsTotal counts invalid endpoints skipped in continue-on-error mode
+	skippedEndpointsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "skipped_endpoints_total",
+		Help:      "Number of invalid endpoints skipped while applying changes in continue-on-error mode.",
+	}, []string{"record_type"})
+
+	// applyLockWaitSeconds measures the time ApplyChanges calls wait for the one in progress to complete
+	applyLockWaitSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "apply_lock_wait_seconds",
+		Help:      "Time spent waiting for a concurrent ApplyChanges call to complete before applying changes.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+
+	// recordsFetchesSharedTotal counts Records calls served by the fetch of a concurrent call
+	recordsFetchesSharedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "records_fetches_shared_total",
+		Help:      "Number of Records calls that shared the router fetch of a concurrent call instead of fetching the records themselves.",
+	})
+
+	// staleRecordsGauge reports whether Records is serving the last records fetched, as the routers cannot be reached
+	staleRecordsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "stale_records",
+		Help:      "Set to 1 while the last records fetched are served because the routers cannot be reached, and changes are refused.",
+	})
+
+	// staleRecordsServedTotal counts Records calls served with the last records fetched
+	staleRecordsServedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "stale_records_served_total",
+		Help:      "Number of times the last records fetched were served because the routers could not be reached.",
+	})
+
+	// recordsResponseBytes observes the size of the records fetched from each router
+	recordsResponseBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "records_response_bytes",
+		Help:      "Size of the responses listing static DNS records, by router and by the columns fetched (all or targets).",
+		Buckets:   prometheus.ExponentialBuckets(256, 4, 10),
+	}, []string{"router", "columns"})
+
+	// deleteConflictsTotal counts records left in place because they changed since they were read
+	deleteConflictsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "delete_conflicts_total",
+		Help:      "Number of records not deleted because their fields changed on the router since external-dns read them.",
+	}, []string{"record_type"})
+)
@@ -2,20 +2,100 @@ package mikrotik
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 	"sigs.k8s.io/external-dns/endpoint"
 	"sigs.k8s.io/external-dns/plan"
 	"sigs.k8s.io/external-dns/provider"
 )
 
+// ErrStaleRecords is returned by ApplyChanges while Records serves stale records
+var ErrStaleRecords = errors.New("refusing to apply changes planned against stale records, the routers could not be reached")
+
+// SkippedEndpointsError is returned by ApplyChanges in continue-on-error mode when every change was applied
+// except for the invalid endpoints it skipped. It reports a partial success rather than a failure, since
+// retrying the same changes would skip the same endpoints again.
+type SkippedEndpointsError struct {
+	Endpoints []*EndpointError
+}
+
+func (e *SkippedEndpointsError) Error() string {
+	return fmt.Sprintf("skipped %d invalid endpoints:\n%v", len(e.Endpoints), errors.Join(e.Unwrap()...))
+}
+
+func (e *SkippedEndpointsError) Unwrap() []error {
+	errs := make([]error, len(e.Endpoints))
+	for i, err := range e.Endpoints {
+		errs[i] = err
+	}
+	return errs
+}
+
+// SkippedEndpoints returns the number of endpoints that were skipped
+func (e *SkippedEndpointsError) SkippedEndpoints() int {
+	return len(e.Endpoints)
+}
+
 // MikrotikProvider is a helper class for working with mikrotik
 type MikrotikProvider struct {
 	provider.BaseProvider
 
-	client       *MikrotikApiClient
+	client       DNSClient
+	replicas     []DNSClient
+	zones        []*zoneRouter
+	defaults     *MikrotikDefaults
 	domainFilter *endpoint.DomainFilter
+
+	// makeBeforeBreak delays the deletion of replaced records until their replacements are created
+	makeBeforeBreak bool
+	// continueOnError skips invalid endpoints instead of rejecting the whole change set
+	continueOnError bool
+	// applyWorkers is the number of endpoints with different names or types applied concurrently
+	applyWorkers int
+	// checkDeleteConflicts leaves in place the records that changed since the endpoints deleting them were read
+	checkDeleteConflicts bool
+
+	// applyMu serializes ApplyChanges calls, so that overlapping change sets do not interleave
+	applyMu sync.Mutex
+	// recordsGroup lets concurrent Records calls share a single fetch
+	recordsGroup singleflight.Group
+
+	// staleRecordsMaxAge is how long the last records fetched may be served while the routers cannot be reached
+	staleRecordsMaxAge time.Duration
+	// lastRecordsMu guards the last records fetched, and whether Records is serving them stale
+	lastRecordsMu sync.Mutex
+	lastRecords   []*endpoint.Endpoint
+	lastRecordsAt time.Time
+	servingStale  bool
+}
+
+// zoneRouter is a router that owns every record within a DNS zone
+type zoneRouter struct {
+	zone   string
+	filter *endpoint.DomainFilter
+	client DNSClient
+}
+
+// filteredChanges is a change set processed by filterChanges
+type filteredChanges struct {
+	*plan.Changes
+
+	// Replaced holds the old endpoints of updates that cannot be applied in place,
+	// whose records are deleted once their replacements in Create exist in make-before-break mode
+	Replaced []*endpoint.Endpoint
+}
+
+// routedChanges is the subset of a change set owned by a zone router, or by the primary router if zone is nil
+type routedChanges struct {
+	zone    *zoneRouter
+	changes *filteredChanges
 }
 
 // NewMikrotikProvider initializes a new DNSProvider, of the Mikrotik variety
@@ -27,32 +107,236 @@ func NewMikrotikProvider(domainFilter *endpoint.DomainFilter, defaults *Mikrotik
 	}
 
 	// Ensure the Client can connect to the API by fetching system info
-	info, err := client.GetSystemInfo()
+	info, err := client.GetSystemInfo(context.Background())
 	if err != nil {
 		log.Errorf("failed to connect to the MikroTik RouterOS API Endpoint: %v", err)
 		return nil, err
 	}
 	log.Infof("connected to board %s running RouterOS version %s (%s)", info.BoardName, info.Version, info.ArchitectureName)
 
+	// Create a client for every replica router. Replicas that cannot be reached right now are not fatal,
+	// since changes are fanned out to them on every ApplyChanges call and failures are reported there.
+	var replicas []DNSClient
+	for _, replicaURL := range config.ReplicaBaseUrls {
+		replicaConfig, err := config.forRouter(replicaURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure replica router %s: %w", replicaURL, err)
+		}
+
+		replica, err := NewMikrotikClient(replicaConfig, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the MikroTik client for replica %s: %w", replicaConfig.BaseUrl, err)
+		}
+
+		info, err := replica.GetSystemInfo(context.Background())
+		if err != nil {
+			log.Warnf("failed to connect to replica router %s: %v", replicaConfig.BaseUrl, err)
+		} else {
+			log.Infof("connected to replica board %s running RouterOS version %s (%s)", info.BoardName, info.Version, info.ArchitectureName)
+		}
+
+		replicas = append(replicas, withSnapshot(replica, config.CacheMaxAge))
+	}
+
+	// Create a client for the router owning each zone. Like replicas, zone routers that cannot be reached
+	// right now are not fatal, as every Records and ApplyChanges call will report them.
+	var zones []*zoneRouter
+	for zone, zoneURL := range config.ZoneBaseUrls {
+		zone = strings.TrimSuffix(strings.ToLower(zone), ".")
+
+		zoneConfig, err := config.forRouter(zoneURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure router for zone %s: %w", zone, err)
+		}
+
+		zoneClient, err := NewMikrotikClient(zoneConfig, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the MikroTik client for zone %s: %w", zone, err)
+		}
+
+		info, err := zoneClient.GetSystemInfo(context.Background())
+		if err != nil {
+			log.Warnf("failed to connect to router %s for zone %s: %v", zoneConfig.BaseUrl, zone, err)
+		} else {
+			log.Infof("connected to board %s running RouterOS version %s (%s) for zone %s", info.BoardName, info.Version, info.ArchitectureName, zone)
+		}
+
+		zones = append(zones, &zoneRouter{
+			zone:   zone,
+			filter: endpoint.NewDomainFilter([]string{zone}),
+			client: withSnapshot(zoneClient, config.CacheMaxAge),
+		})
+	}
+
 	// If the client connects properly, create the DNS Provider
+	p := &MikrotikProvider{
+		client:       withSnapshot(client, config.CacheMaxAge),
+		replicas:     replicas,
+		zones:        sortZones(zones),
+		defaults:     defaults,
+		domainFilter: domainFilter,
+
+		makeBeforeBreak:      config.MakeBeforeBreak,
+		continueOnError:      config.ContinueOnError,
+		applyWorkers:         config.ApplyWorkers,
+		checkDeleteConflicts: config.CheckDeleteConflicts,
+
+		staleRecordsMaxAge: config.StaleRecordsMaxAge,
+	}
+
+	return p, nil
+}
+
+// withSnapshot wraps a client with a snapshot of its records if maxAge is positive
+func withSnapshot(client DNSClient, maxAge time.Duration) DNSClient {
+	if maxAge <= 0 {
+		return client
+	}
+	return newSnapshotDNSClient(client, maxAge)
+}
+
+// withChangeSetSnapshot fetches the records of every name and type of the change set with a single query, and wraps
+// the client so that the lookups needed to apply the change set are answered from them. Clients that already keep
+// a snapshot of every record are returned as-is, as are clients for which the query failed.
+func withChangeSetSnapshot(ctx context.Context, client DNSClient, changes *filteredChanges) DNSClient {
+	if _, ok := client.(*snapshotDNSClient); ok {
+		return client
+	}
+
+	var scope []DNSRecordFilter
+	seen := map[DNSRecordFilter]bool{}
+	for _, endpoints := range [][]*endpoint.Endpoint{changes.Delete, changes.Replaced, changes.UpdateOld, changes.UpdateNew, changes.Create} {
+		for _, ep := range endpoints {
+			filter := DNSRecordFilter{Name: ep.DNSName, Type: ep.RecordType}
+			if filter.Name != "" && filter.Type != "" && !seen[filter] {
+				seen[filter] = true
+				scope = append(scope, filter)
+			}
+		}
+	}
+	if len(scope) == 0 {
+		return client
+	}
+
+	records, err := client.QueryDNSRecords(ctx, scope)
+	if err != nil {
+		log.Warnf("failed to fetch the DNS records of the change set from router %s at once, looking them up one by one: %v", client, err)
+		return client
+	}
+	log.Debugf("fetched %d DNS records for the %d names and types of the change set from router %s", len(records), len(scope), client)
+	return newChangeSetSnapshot(client, scope, records)
+}
+
+// NewMikrotikProviderWithClient initializes a new DNSProvider on top of any DNSClient implementation,
+// such as MemoryDNSClient. Unlike NewMikrotikProvider, it does not contact the router.
+func NewMikrotikProviderWithClient(domainFilter *endpoint.DomainFilter, defaults *MikrotikDefaults, client DNSClient) (provider.Provider, error) {
+	if client == nil {
+		return nil, fmt.Errorf("a DNS client is required")
+	}
+	if defaults == nil {
+		defaults = &MikrotikDefaults{}
+	}
+
 	p := &MikrotikProvider{
 		client:       client,
+		defaults:     defaults,
 		domainFilter: domainFilter,
 	}
 
 	return p, nil
 }
 
-// Records returns the list of all DNS records.
+// Records returns the list of all DNS records, as seen by the primary router and the router owning each zone.
+// Concurrent calls share the records fetched by the first one, rather than each fetching them from the routers.
 func (p *MikrotikProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	// The fetch is not cancelled along with the call that started it, as other calls may be waiting for it
+	leader := false
+	result := p.recordsGroup.DoChan("records", func() (any, error) {
+		leader = true
+		return p.fetchRecordsOrStale(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if res.Shared && !leader {
+			log.Debugf("sharing the records fetched by a concurrent call")
+			recordsFetchesSharedTotal.Inc()
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]*endpoint.Endpoint), nil
+	}
+}
+
+// fetchRecordsOrStale fetches the records of every router. If they cannot be fetched, the last records fetched
+// are returned instead, as long as they are not older than staleRecordsMaxAge. Changes are refused until
+// the records can be fetched again, since they would be planned against a state that may not be current.
+func (p *MikrotikProvider) fetchRecordsOrStale(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	endpoints, err := p.fetchRecords(ctx)
+
+	p.lastRecordsMu.Lock()
+	defer p.lastRecordsMu.Unlock()
+
+	if err == nil {
+		if p.servingStale {
+			log.Infof("fetched the records from the routers again, no longer serving stale records")
+		}
+		p.servingStale = false
+		staleRecordsGauge.Set(0)
+		if p.staleRecordsMaxAge > 0 {
+			p.lastRecords = copyEndpoints(endpoints)
+			p.lastRecordsAt = time.Now()
+		}
+		return endpoints, nil
+	}
+
+	if p.staleRecordsMaxAge <= 0 || p.lastRecords == nil {
+		return nil, err
+	}
+	age := time.Since(p.lastRecordsAt)
+	if age > p.staleRecordsMaxAge {
+		log.Errorf("failed to fetch the records, and the last records fetched %s ago are too old to be served: %v", age.Round(time.Second), err)
+		return nil, err
+	}
+
+	log.Warnf("failed to fetch the records, serving the records fetched %s ago and refusing changes until the routers can be reached: %v", age.Round(time.Second), err)
+	p.servingStale = true
+	staleRecordsGauge.Set(1)
+	staleRecordsServedTotal.Inc()
+	return copyEndpoints(p.lastRecords), nil
+}
+
+// copyEndpoints returns a deep copy of the endpoints, so that callers cannot modify the ones kept
+func copyEndpoints(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	copied := make([]*endpoint.Endpoint, len(endpoints))
+	for i, ep := range endpoints {
+		copied[i] = ep.DeepCopy()
+	}
+	return copied
+}
+
+// fetchRecords fetches the records of every router and aggregates them to endpoints
+func (p *MikrotikProvider) fetchRecords(ctx context.Context) ([]*endpoint.Endpoint, error) {
 	// Get all managed records (no name filter)
-	records, err := p.client.GetDNSRecords(DNSRecordFilter{})
+	records, err := p.client.GetDNSRecords(ctx, DNSRecordFilter{})
 	if err != nil {
 		return nil, err
 	}
 
 	// Filter managed records
-	filteredRecords := p.filterManagedRecords(records)
+	filteredRecords := p.filterZoneRecords(p.filterManagedRecords(records), nil)
+
+	// Merge the records of each zone, as read from the router owning it
+	for _, zone := range p.zones {
+		zoneRecords, err := zone.client.GetDNSRecords(ctx, DNSRecordFilter{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get records for zone %s from router %s: %w", zone.zone, zone.client, err)
+		}
+		filteredRecords = append(filteredRecords, p.filterZoneRecords(p.filterManagedRecords(zoneRecords), zone)...)
+	}
 
 	// Aggregate records to endpoints
 	endpoints, err := p.aggregateRecordsToEndpoints(filteredRecords)
@@ -65,27 +349,246 @@ func (p *MikrotikProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, e
 }
 
 // ApplyChanges applies a given set of changes in the DNS provider.
+// The changes are applied to the primary router and to every replica, even if some of them fail.
 func (p *MikrotikProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
-	changes, err := p.filterChanges(changes)
+	// Wait for any change set being applied, and give up if the caller did in the meantime
+	waitStart := time.Now()
+	p.applyMu.Lock()
+	defer p.applyMu.Unlock()
+	applyLockWaitSeconds.Observe(time.Since(waitStart).Seconds())
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("gave up waiting for the changes being applied: %w", err)
+	}
+
+	p.lastRecordsMu.Lock()
+	servingStale := p.servingStale
+	p.lastRecordsMu.Unlock()
+	if servingStale {
+		log.Warn(ErrStaleRecords)
+		return ErrStaleRecords
+	}
+
+	changes, invalid := validateChanges(changes)
+	var invalidErrs []error
+	for _, err := range invalid {
+		invalidErrs = append(invalidErrs, err)
+	}
+	if len(invalid) > 0 && !p.continueOnError {
+		return fmt.Errorf("rejected %d invalid endpoints, no changes were applied:\n%w", len(invalid), errors.Join(invalidErrs...))
+	}
+
+	// In continue-on-error mode, the invalid endpoints are reported once the valid ones are applied
+	for _, err := range invalid {
+		log.Warnf("skipping invalid endpoint %s (%s, targets %v) from resource %q: %v",
+			err.Endpoint.DNSName, err.Endpoint.RecordType, err.Endpoint.Targets, err.Endpoint.Labels[endpoint.ResourceLabelKey], err.Err)
+		skippedEndpointsTotal.WithLabelValues(err.Endpoint.RecordType).Inc()
+	}
+
+	filtered, err := p.filterChanges(changes)
 	if err != nil {
 		return fmt.Errorf("failed to process changes: %w", err)
 	}
 
-	for _, endpoint := range append(changes.UpdateOld, changes.Delete...) {
-		if err := p.client.DeleteRecordsFromEndpoint(endpoint); err != nil {
-			return err
+	var errs []error
+	for _, routed := range p.routeChanges(filtered) {
+		for _, client := range p.clientsFor(routed.zone) {
+			if err := p.applyChangesToClient(ctx, client, routed.changes); err != nil {
+				log.Errorf("failed to apply changes to router %s: %v", client, err)
+				errs = append(errs, fmt.Errorf("router %s: %w", client, err))
+			}
+		}
+	}
+
+	if len(invalid) == 0 {
+		return errors.Join(errs...)
+	}
+	skipped := &SkippedEndpointsError{Endpoints: invalid}
+	if len(errs) == 0 {
+		return skipped
+	}
+	return errors.Join(append(errs, skipped)...)
+}
+
+// applyChangesToClient applies a set of already filtered changes to a single router.
+// Records are deleted first, then updated and created. In make-before-break mode, the records replaced
+// by an update are only deleted after every record was created.
+// Every change is journaled, and if any step fails, the changes already applied are rolled back in reverse
+// order so that the router is left as it was. Records left in place because of a conflict are reported
+// without rolling anything back.
+func (p *MikrotikProvider) applyChangesToClient(ctx context.Context, client DNSClient, changes *filteredChanges) error {
+	client = withChangeSetSnapshot(ctx, client, changes)
+	j := newJournal(client)
+	conflicts, err := p.applyJournaledChanges(ctx, client, j, changes)
+	if err != nil {
+		if rollbackErr := j.rollback(ctx); rollbackErr != nil {
+			err = fmt.Errorf("%w (rollback failed, the router may be left partially updated: %w)", err, rollbackErr)
+		} else {
+			err = fmt.Errorf("%w (changes rolled back)", err)
+		}
+	}
+
+	return errors.Join(err, conflicts)
+}
+
+// applyJournaledChanges applies the changes to a single router, recording every change in the journal.
+// The records left in place because of a conflict are returned separately from the error.
+func (p *MikrotikProvider) applyJournaledChanges(ctx context.Context, client DNSClient, j *journal, changes *filteredChanges) (conflicts error, err error) {
+	// Convert the endpoints to create before changing anything, so that an invalid endpoint fails early
+	records, err := newRecordsFromEndpoints(changes.Create, p.defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	deletes := changes.Delete
+	if !p.makeBeforeBreak {
+		deletes = append(slices.Clone(deletes), changes.Replaced...)
+	}
+	if conflicts, err = p.deleteJournaledRecords(ctx, client, j, deletes); err != nil {
+		return conflicts, err
+	}
+
+	// Look up the replaced records before creating anything, so that their replacements are not mistaken for them
+	var replaced []DNSRecord
+	if p.makeBeforeBreak {
+		var replacedConflicts error
+		replaced, replacedConflicts, err = p.recordsToDelete(ctx, client, changes.Replaced)
+		conflicts = errors.Join(conflicts, replacedConflicts)
+		if err != nil {
+			return conflicts, err
+		}
+	}
+
+	// Update records in place, as paired up by filterChanges
+	missing, err := updateRecordsFromEndpoints(ctx, client, j, p.applyWorkers, p.defaults, changes.UpdateOld, changes.UpdateNew)
+	if err != nil {
+		return conflicts, fmt.Errorf("failed to update DNS records: %w", err)
+	}
+
+	// Create the records of every endpoint at once, so that the client can batch them
+	records = append(records, missing...)
+	if err := createJournaledRecords(ctx, client, j, p.applyWorkers, records); err != nil {
+		return conflicts, fmt.Errorf("failed to create DNS records: %w", err)
+	}
+
+	if len(replaced) > 0 {
+		if err := deleteRecords(ctx, client, j, replaced); err != nil {
+			return conflicts, fmt.Errorf("failed to delete replaced DNS records: %w", err)
 		}
 	}
 
-	for _, endpoint := range append(changes.Create, changes.UpdateNew...) {
-		if _, err := p.client.CreateRecordsFromEndpoint(endpoint); err != nil {
+	return conflicts, nil
+}
+
+// createJournaledRecords creates the records, split into one batch per worker. Records with the same name
+// and type are created by the same batch, in order. Records that already exist are not created again.
+func createJournaledRecords(ctx context.Context, client DNSClient, j *journal, workers int, records []*DNSRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	// Spread the names and types across the batches, in order of first appearance
+	batches := make([][]*DNSRecord, min(max(workers, 1), len(records)))
+	batchOf := map[string]int{}
+	for _, record := range records {
+		key := recordKey(record)
+		b, ok := batchOf[key]
+		if !ok {
+			b = len(batchOf) % len(batches)
+			batchOf[key] = b
+		}
+		batches[b] = append(batches[b], record)
+	}
+	batches = slices.DeleteFunc(batches, func(batch []*DNSRecord) bool { return len(batch) == 0 })
+
+	batchKey := func(batch []*DNSRecord) string { return recordKey(batch[0]) }
+	errs := forEachInGroups(workers, batches, batchKey, func(_ int, batch []*DNSRecord) error {
+		batch, err := skipExistingRecords(ctx, client, j, batch)
+		if err != nil || len(batch) == 0 {
 			return err
 		}
+		created, err := client.CreateDNSRecords(ctx, batch)
+		for _, record := range created {
+			j.created(record)
+		}
+		return err
+	})
+
+	return errors.Join(errs...)
+}
+
+// deleteJournaledRecords deletes the records of every endpoint at once, so that the client can batch them.
+// The records left in place because of a conflict are returned separately from the error.
+func (p *MikrotikProvider) deleteJournaledRecords(ctx context.Context, client DNSClient, j *journal, endpoints []*endpoint.Endpoint) (conflicts error, err error) {
+	records, conflicts, err := p.recordsToDelete(ctx, client, endpoints)
+	if err != nil {
+		return conflicts, err
+	}
+	if err := deleteRecords(ctx, client, j, records); err != nil {
+		return conflicts, fmt.Errorf("failed to delete DNS records: %w", err)
+	}
+	return conflicts, nil
+}
+
+// recordsToDelete looks up the records of the endpoints to delete. When checking for conflicts, the records are
+// queried from the router itself rather than from a snapshot of every record, and the ones that changed since the
+// endpoints were read are left out and returned as conflicts.
+func (p *MikrotikProvider) recordsToDelete(ctx context.Context, client DNSClient, endpoints []*endpoint.Endpoint) (records []DNSRecord, conflicts error, err error) {
+	if !p.checkDeleteConflicts {
+		records, err = queryRecordsForEndpoints(ctx, client, endpoints)
+		return records, nil, err
+	}
+
+	if records, err = queryRecordsForEndpoints(ctx, uncached(client), endpoints); err != nil {
+		return nil, nil, err
+	}
+	records, conflicts = withoutDeleteConflicts(records, endpoints, p.defaults)
+	return records, conflicts, nil
+}
+
+// deleteRecords deletes the given records, recording the ones actually deleted in the journal
+func deleteRecords(ctx context.Context, client DNSClient, j *journal, records []DNSRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var ids []string
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+
+	if err := client.DeleteDNSRecords(ctx, ids); err != nil {
+		// Some records may have been deleted before the failure
+		if journalErr := j.deletedUnlessPresent(context.WithoutCancel(ctx), records); journalErr != nil {
+			return errors.Join(err, journalErr)
+		}
+		return err
 	}
 
+	for _, record := range records {
+		j.deleted(record)
+	}
 	return nil
 }
 
+// Ready returns an error listing every router currently considered unreachable by its client.
+func (p *MikrotikProvider) Ready() error {
+	clients := append([]DNSClient{p.client}, p.replicas...)
+	for _, zone := range p.zones {
+		clients = append(clients, zone.client)
+	}
+
+	var errs []error
+	for _, client := range clients {
+		if reporter, ok := client.(readinessReporter); ok {
+			if err := reporter.Ready(); err != nil {
+				errs = append(errs, fmt.Errorf("router %s: %w", client, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // GetDomainFilter returns the domain filter for the provider.
 func (p *MikrotikProvider) GetDomainFilter() endpoint.DomainFilterInterface {
 	return p.domainFilter
@@ -94,6 +597,102 @@ func (p *MikrotikProvider) GetDomainFilter() endpoint.DomainFilterInterface {
 // ================================================================================================
 // UTILS
 // ================================================================================================
+// clientsFor returns every router changes within a zone should be written to.
+// Changes outside of any zone go to the primary router first, followed by its replicas.
+func (p *MikrotikProvider) clientsFor(zone *zoneRouter) []DNSClient {
+	if zone != nil {
+		return []DNSClient{zone.client}
+	}
+	return append([]DNSClient{p.client}, p.replicas...)
+}
+
+// zoneFor returns the most specific zone the given DNS name belongs to, or nil if no zone claims it.
+func (p *MikrotikProvider) zoneFor(dnsName string) *zoneRouter {
+	for _, zone := range p.zones {
+		if zone.filter.Match(dnsName) {
+			return zone
+		}
+	}
+	return nil
+}
+
+// sortZones orders zones from the most to the least specific, so that nested zones take precedence.
+func sortZones(zones []*zoneRouter) []*zoneRouter {
+	slices.SortFunc(zones, func(a, b *zoneRouter) int {
+		if diff := strings.Count(b.zone, ".") - strings.Count(a.zone, "."); diff != 0 {
+			return diff
+		}
+		return strings.Compare(a.zone, b.zone)
+	})
+	return zones
+}
+
+// filterZoneRecords keeps only the records owned by the given zone, or by no zone at all if zone is nil.
+func (p *MikrotikProvider) filterZoneRecords(records []DNSRecord, zone *zoneRouter) []DNSRecord {
+	if len(p.zones) == 0 {
+		return records
+	}
+
+	var filtered []DNSRecord
+	for _, record := range records {
+		if p.zoneFor(record.Name) != zone {
+			log.Debugf("Skipping record %s as it is not owned by this router", record.Name)
+			continue
+		}
+		filtered = append(filtered, record)
+	}
+
+	return filtered
+}
+
+// routeChanges splits a change set by the zone owning each endpoint. Changes outside of any zone come first.
+func (p *MikrotikProvider) routeChanges(changes *filteredChanges) []routedChanges {
+	if len(p.zones) == 0 {
+		return []routedChanges{{zone: nil, changes: changes}}
+	}
+
+	routed := map[*zoneRouter]*filteredChanges{}
+	route := func(ep *endpoint.Endpoint) *filteredChanges {
+		zone := p.zoneFor(ep.DNSName)
+		if zone != nil {
+			log.Debugf("Routing endpoint %s to the router of zone %s", ep.DNSName, zone.zone)
+		}
+		if routed[zone] == nil {
+			routed[zone] = &filteredChanges{Changes: &plan.Changes{}}
+		}
+		return routed[zone]
+	}
+
+	for _, ep := range changes.Create {
+		c := route(ep)
+		c.Create = append(c.Create, ep)
+	}
+	for _, ep := range changes.UpdateOld {
+		c := route(ep)
+		c.UpdateOld = append(c.UpdateOld, ep)
+	}
+	for _, ep := range changes.UpdateNew {
+		c := route(ep)
+		c.UpdateNew = append(c.UpdateNew, ep)
+	}
+	for _, ep := range changes.Delete {
+		c := route(ep)
+		c.Delete = append(c.Delete, ep)
+	}
+	for _, ep := range changes.Replaced {
+		c := route(ep)
+		c.Replaced = append(c.Replaced, ep)
+	}
+
+	var result []routedChanges
+	for _, zone := range append([]*zoneRouter{nil}, p.zones...) {
+		if routed[zone] != nil {
+			result = append(result, routedChanges{zone: zone, changes: routed[zone]})
+		}
+	}
+	return result
+}
+
 // getProviderSpecific retrieves a provider-specific property from the endpoint, looking both values
 // that could come from annotations (i.e. webhook/%s) as well as values from CRD (i.e. %s).
 // If the property is not found, it returns the specified default value.
@@ -130,8 +729,8 @@ func (p *MikrotikProvider) compareEndpointsMetadata(a *endpoint.Endpoint, b *end
 		return false
 	}
 
-	aRelevantTTL := a.RecordTTL != 0 && a.RecordTTL != endpoint.TTL(p.client.DefaultTTL)
-	bRelevantTTL := b.RecordTTL != 0 && b.RecordTTL != endpoint.TTL(p.client.DefaultTTL)
+	aRelevantTTL := a.RecordTTL != 0 && a.RecordTTL != endpoint.TTL(p.defaults.DefaultTTL)
+	bRelevantTTL := b.RecordTTL != 0 && b.RecordTTL != endpoint.TTL(p.defaults.DefaultTTL)
 	if a.RecordTTL != b.RecordTTL && (aRelevantTTL || bRelevantTTL) {
 		log.Debugf("RecordTTL mismatch: %v != %v", a.RecordTTL, b.RecordTTL)
 		return false
@@ -139,8 +738,8 @@ func (p *MikrotikProvider) compareEndpointsMetadata(a *endpoint.Endpoint, b *end
 
 	aComment := p.getProviderSpecificOrDefault(a, "comment", "")
 	bComment := p.getProviderSpecificOrDefault(b, "comment", "")
-	aRelevantComment := aComment != "" && aComment != p.client.DefaultComment
-	bRelevantComment := bComment != "" && bComment != p.client.DefaultComment
+	aRelevantComment := aComment != "" && aComment != p.defaults.DefaultComment
+	bRelevantComment := bComment != "" && bComment != p.defaults.DefaultComment
 	if aComment != bComment && (aRelevantComment || bRelevantComment) {
 		log.Debugf("Comment mismatch: %v != %v", aComment, bComment)
 		return false
@@ -288,21 +887,75 @@ func (p *MikrotikProvider) aggregateRecordsToEndpoints(records []DNSRecord) ([]*
 	return endpoints, nil
 }
 
-// filterChanges processes the given plan.Changes to optimize updates by splitting them into deletes and creates
-func (p *MikrotikProvider) filterChanges(changes *plan.Changes) (*plan.Changes, error) {
-	if len(changes.UpdateOld) == 0 || len(changes.UpdateNew) == 0 {
+// validateChanges checks that every endpoint to create or update can be converted to DNS records, so that
+// invalid endpoints are known before touching any router. It returns the changes without the invalid endpoints,
+// along with an *EndpointError for each of them. An invalid update is dropped along with its old endpoint.
+func validateChanges(changes *plan.Changes) (*plan.Changes, []*EndpointError) {
+	validate := func(ep *endpoint.Endpoint) *EndpointError {
+		if len(ep.Targets) == 0 {
+			return nil
+		}
+		if _, err := NewDNSRecords(ep); err != nil {
+			return &EndpointError{Endpoint: ep, Err: err}
+		}
+		return nil
+	}
+
+	valid := &plan.Changes{Delete: changes.Delete}
+	var errs []*EndpointError
+	for _, ep := range changes.Create {
+		if err := validate(ep); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		valid.Create = append(valid.Create, ep)
+	}
+	for i, ep := range changes.UpdateNew {
+		if err := validate(ep); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		valid.UpdateNew = append(valid.UpdateNew, ep)
+		if i < len(changes.UpdateOld) {
+			valid.UpdateOld = append(valid.UpdateOld, changes.UpdateOld[i])
+		}
+	}
+	if len(changes.UpdateOld) > len(changes.UpdateNew) {
+		// Let filterChanges report the mismatch
+		valid.UpdateOld = append(valid.UpdateOld, changes.UpdateOld[len(changes.UpdateNew):]...)
+	}
+	if len(errs) == 0 {
 		return changes, nil
 	}
 
+	return valid, errs
+}
+
+// filterChanges processes the given plan.Changes to optimize updates. Targets that stay the same or change
+// one-for-one are paired up in UpdateOld and UpdateNew, with a single target per endpoint, so that their
+// records can be updated in place. The other targets are split into replaced endpoints and creates.
+func (p *MikrotikProvider) filterChanges(changes *plan.Changes) (*filteredChanges, error) {
+	if len(changes.UpdateOld) == 0 || len(changes.UpdateNew) == 0 {
+		return &filteredChanges{
+			Changes: &plan.Changes{
+				Create: slices.Concat(changes.Create, changes.UpdateNew),
+				Delete: changes.Delete,
+			},
+			Replaced: changes.UpdateOld,
+		}, nil
+	}
+
 	if len(changes.UpdateOld) != len(changes.UpdateNew) {
 		return nil, fmt.Errorf("mismatched UpdateOld and UpdateNew lengths: %d vs %d", len(changes.UpdateOld), len(changes.UpdateNew))
 	}
 
-	newChanges := &plan.Changes{
-		Create:    changes.Create,
-		UpdateOld: []*endpoint.Endpoint{},
-		UpdateNew: []*endpoint.Endpoint{},
-		Delete:    changes.Delete,
+	newChanges := &filteredChanges{
+		Changes: &plan.Changes{
+			Create:    changes.Create,
+			UpdateOld: []*endpoint.Endpoint{},
+			UpdateNew: []*endpoint.Endpoint{},
+			Delete:    changes.Delete,
+		},
 	}
 
 	// Process matched pairs (updateOld and updateNew are matched by index)
@@ -314,24 +967,57 @@ func (p *MikrotikProvider) filterChanges(changes *plan.Changes) (*plan.Changes,
 			return nil, fmt.Errorf("mismatched UpdateOld and UpdateNew endpoints at index %d: %v vs %v", key, oldEndpoint, newEndpoint)
 		}
 
-		// if metadata matches, do partial update
-		if p.compareEndpointsMetadata(oldEndpoint, newEndpoint) {
-			deleteEndpoint, createEndpoint := p.diffEndpoints(oldEndpoint, newEndpoint)
-			if deleteEndpoint != nil {
-				newChanges.Delete = append(newChanges.Delete, deleteEndpoint)
+		// a record cannot be switched between a name and a regexp in place
+		if p.getProviderSpecificOrDefault(oldEndpoint, "regexp", "") != p.getProviderSpecificOrDefault(newEndpoint, "regexp", "") {
+			newChanges.Replaced = append(newChanges.Replaced, oldEndpoint)
+			newChanges.Create = append(newChanges.Create, newEndpoint)
+			continue
+		}
+
+		// if metadata changed, the records of the targets that stay the same must be updated
+		pair := func(oldTarget, newTarget string) {
+			newChanges.UpdateOld = append(newChanges.UpdateOld, withTargets(oldEndpoint, oldTarget))
+			newChanges.UpdateNew = append(newChanges.UpdateNew, withTargets(newEndpoint, newTarget))
+		}
+		if !p.compareEndpointsMetadata(oldEndpoint, newEndpoint) {
+			for _, target := range newEndpoint.Targets {
+				if slices.Contains(oldEndpoint.Targets, target) {
+					pair(target, target)
+				}
 			}
-			if createEndpoint != nil {
-				newChanges.Create = append(newChanges.Create, createEndpoint)
+		}
+
+		// targets that changed are updated one-for-one, the remaining ones are deleted or created
+		deleteEndpoint, createEndpoint := p.diffEndpoints(oldEndpoint, newEndpoint)
+		if deleteEndpoint != nil && createEndpoint != nil {
+			slices.Sort(deleteEndpoint.Targets)
+			slices.Sort(createEndpoint.Targets)
+
+			paired := min(len(deleteEndpoint.Targets), len(createEndpoint.Targets))
+			for i := range paired {
+				pair(deleteEndpoint.Targets[i], createEndpoint.Targets[i])
 			}
-		} else {
-			newChanges.Delete = append(newChanges.Delete, oldEndpoint)
-			newChanges.Create = append(newChanges.Create, newEndpoint)
+			deleteEndpoint.Targets = deleteEndpoint.Targets[paired:]
+			createEndpoint.Targets = createEndpoint.Targets[paired:]
+		}
+		if deleteEndpoint != nil && len(deleteEndpoint.Targets) > 0 {
+			newChanges.Replaced = append(newChanges.Replaced, deleteEndpoint)
+		}
+		if createEndpoint != nil && len(createEndpoint.Targets) > 0 {
+			newChanges.Create = append(newChanges.Create, createEndpoint)
 		}
 	}
 
 	return newChanges, nil
 }
 
+// withTargets returns a copy of the endpoint with the given targets
+func withTargets(ep *endpoint.Endpoint, targets ...string) *endpoint.Endpoint {
+	copied := *ep
+	copied.Targets = targets
+	return &copied
+}
+
 // diffEndpoints computes the difference between two endpoints and returns two new endpoints:
 // one for targets to delete and one for targets to add. If there are no targets to delete or add,
 // the corresponding endpoint will be nil.
@@ -368,9 +1054,11 @@ func (p *MikrotikProvider) diffEndpoints(oldEndpoint, newEndpoint *endpoint.Endp
 	log.Debugf("Targets to add: %v", toAdd)
 
 	endpointToDelete := &endpoint.Endpoint{
-		DNSName:    oldEndpoint.DNSName,
-		RecordType: oldEndpoint.RecordType,
-		Targets:    toDelete,
+		DNSName:          oldEndpoint.DNSName,
+		RecordType:       oldEndpoint.RecordType,
+		Targets:          toDelete,
+		RecordTTL:        oldEndpoint.RecordTTL,
+		ProviderSpecific: oldEndpoint.ProviderSpecific,
 	}
 	if len(toDelete) == 0 {
 		log.Debug("No targets to delete, returning nil for delete endpoint")
@@ -30,6 +30,7 @@ func newIntegrationProvider(t *testing.T) *MikrotikProvider {
 	}
 	return &MikrotikProvider{
 		client:       client,
+		defaults:     defaults,
 		domainFilter: endpoint.NewDomainFilter([]string{"integration.test"}),
 	}
 }
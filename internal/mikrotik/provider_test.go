@@ -3,10 +3,18 @@ package mikrotik
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"sigs.k8s.io/external-dns/endpoint"
 	"sigs.k8s.io/external-dns/plan"
 )
@@ -35,13 +43,9 @@ func NewEndpoint(dnsName string, targets []string, recordType string, ttl int64,
 
 func TestGetProviderSpecificOrDefault(t *testing.T) {
 	mikrotikProvider := &MikrotikProvider{
-		client: &MikrotikApiClient{
-			MikrotikDefaults: &MikrotikDefaults{
-				DefaultTTL:     defaultTTL,
-				DefaultComment: defaultComment,
-			},
-			MikrotikConnectionConfig: nil,
-			Client:                   nil,
+		defaults: &MikrotikDefaults{
+			DefaultTTL:     defaultTTL,
+			DefaultComment: defaultComment,
 		},
 	}
 	tests := []struct {
@@ -93,13 +97,9 @@ func TestGetProviderSpecificOrDefault(t *testing.T) {
 
 func TestCompareEndpointsMetadata(t *testing.T) {
 	mikrotikProvider := &MikrotikProvider{
-		client: &MikrotikApiClient{
-			MikrotikDefaults: &MikrotikDefaults{
-				DefaultTTL:     int64(defaultTTL),
-				DefaultComment: defaultComment,
-			},
-			MikrotikConnectionConfig: nil,
-			Client:                   nil,
+		defaults: &MikrotikDefaults{
+			DefaultTTL:     int64(defaultTTL),
+			DefaultComment: defaultComment,
 		},
 	}
 	tests := []struct {
@@ -415,6 +415,7 @@ func TestMikrotikProvider_Records(t *testing.T) {
 
 			provider := &MikrotikProvider{
 				client:       client,
+				defaults:     defaults,
 				domainFilter: domainFilter,
 			}
 
@@ -453,6 +454,7 @@ func TestMikrotikProvider_ApplyChanges(t *testing.T) {
 		simulateAPIError    bool
 		expectedPutCalls    int // Expected number of PUT (create) calls
 		expectedDeleteCalls int // Expected number of DELETE calls
+		expectedPatchCalls  int // Expected number of PATCH (update) calls
 	}{
 		{
 			name: "Successful create operation",
@@ -490,8 +492,9 @@ func TestMikrotikProvider_ApplyChanges(t *testing.T) {
 			},
 			expectError:         false,
 			simulateAPIError:    false,
-			expectedPutCalls:    1, // Smart update might create new targets
-			expectedDeleteCalls: 1, // Smart update might delete old targets
+			expectedPutCalls:    0,
+			expectedDeleteCalls: 0,
+			expectedPatchCalls:  1, // The target changes one-for-one, so the record is updated in place
 		},
 		{
 			name: "Update with overlapping records - should skip identical ones",
@@ -511,8 +514,9 @@ func TestMikrotikProvider_ApplyChanges(t *testing.T) {
 			},
 			expectError:         false,
 			simulateAPIError:    false,
-			expectedPutCalls:    1, // smartUpdate creates new target - note: the identical record should be filtered out by changes()
-			expectedDeleteCalls: 1, // Note: DELETE might be 0 if no existing record is found to delete
+			expectedPutCalls:    0,
+			expectedDeleteCalls: 0,
+			expectedPatchCalls:  1, // Only the changing record is updated, the identical one is skipped
 		},
 		{
 			name: "Update with all identical records - should skip all operations",
@@ -545,15 +549,16 @@ func TestMikrotikProvider_ApplyChanges(t *testing.T) {
 			},
 			expectError:         false,
 			simulateAPIError:    false,
-			expectedPutCalls:    2,
-			expectedDeleteCalls: 1,
+			expectedPutCalls:    1,
+			expectedDeleteCalls: 0,
+			expectedPatchCalls:  1, // 1.1.1.1 is updated to 3.3.3.3, 4.4.4.4 is created
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Counters to track API calls
-			var putCallCount, deleteCallCount int
+			var putCallCount, deleteCallCount, patchCallCount int
 
 			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				// Simulate API error
@@ -569,8 +574,8 @@ func TestMikrotikProvider_ApplyChanges(t *testing.T) {
 					return
 				}
 
-				// Mock DNS records for GET requests (needed for delete and update)
-				if r.URL.Path == "/rest/ip/dns/static" && r.Method == http.MethodGet {
+				// Mock DNS records for GET requests and print commands (needed for delete and update)
+				if r.URL.Path == "/rest/ip/dns/static" && r.Method == http.MethodGet || r.URL.Path == "/rest/ip/dns/static/print" {
 					allRecords := []DNSRecord{
 						{
 							ID:      "*1",
@@ -651,6 +656,14 @@ func TestMikrotikProvider_ApplyChanges(t *testing.T) {
 						},
 					}
 
+					if r.Method == http.MethodPost {
+						w.Header().Set("Content-Type", "application/json")
+						if err := json.NewEncoder(w).Encode(printDNSRecords(t, r, allRecords)); err != nil {
+							t.Errorf("Failed to encode response: %v", err)
+						}
+						return
+					}
+
 					// Filter records based on query parameters
 					query := r.URL.Query()
 					nameFilter := query.Get("name")
@@ -691,6 +704,21 @@ func TestMikrotikProvider_ApplyChanges(t *testing.T) {
 					return
 				}
 
+				// Handle PATCH requests (update)
+				if r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/rest/ip/dns/static/") {
+					patchCallCount++
+					var fields map[string]string
+					if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+						t.Errorf("Failed to decode request: %v", err)
+					}
+					fields[".id"] = strings.TrimPrefix(r.URL.Path, "/rest/ip/dns/static/")
+					w.Header().Set("Content-Type", "application/json")
+					if err := json.NewEncoder(w).Encode(fields); err != nil {
+						t.Errorf("Failed to encode response: %v", err)
+					}
+					return
+				}
+
 				// Handle DELETE requests
 				if r.Method == http.MethodDelete {
 					deleteCallCount++
@@ -722,6 +750,7 @@ func TestMikrotikProvider_ApplyChanges(t *testing.T) {
 
 			provider := &MikrotikProvider{
 				client:       client,
+				defaults:     defaults,
 				domainFilter: domainFilter,
 			}
 
@@ -745,7 +774,947 @@ func TestMikrotikProvider_ApplyChanges(t *testing.T) {
 				if deleteCallCount != tt.expectedDeleteCalls {
 					t.Errorf("Expected %d DELETE calls, got %d", tt.expectedDeleteCalls, deleteCallCount)
 				}
+				if patchCallCount != tt.expectedPatchCalls {
+					t.Errorf("Expected %d PATCH calls, got %d", tt.expectedPatchCalls, patchCallCount)
+				}
+			}
+		})
+	}
+}
+
+func TestMikrotikProvider_ApplyChanges_Replicas(t *testing.T) {
+	tests := []struct {
+		name            string
+		failingReplica  bool
+		expectError     bool
+		expectedPutCall int // Expected number of PUT (create) calls on every router
+	}{
+		{
+			name:            "Changes are fanned out to all routers",
+			failingReplica:  false,
+			expectError:     false,
+			expectedPutCall: 1,
+		},
+		{
+			name:            "Failing replica is reported but does not stop the others",
+			failingReplica:  true,
+			expectError:     true,
+			expectedPutCall: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newRouter := func(fail bool, putCalls *int) *httptest.Server {
+				return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if fail {
+						http.Error(w, "API Error", http.StatusInternalServerError)
+						return
+					}
+					if r.Method == http.MethodPut && r.URL.Path == "/rest/ip/dns/static" {
+						*putCalls++
+						var record DNSRecord
+						if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+							t.Errorf("Failed to decode request: %v", err)
+						}
+						record.ID = "*new"
+						w.Header().Set("Content-Type", "application/json")
+						if err := json.NewEncoder(w).Encode(record); err != nil {
+							t.Errorf("Failed to encode response: %v", err)
+						}
+						return
+					}
+					if r.Method == http.MethodGet && r.URL.Path == "/rest/ip/dns/static" {
+						w.Header().Set("Content-Type", "application/json")
+						fmt.Fprint(w, "[]")
+						return
+					}
+					http.NotFound(w, r)
+				}))
+			}
+
+			var primaryPuts, replicaPuts, failingPuts int
+			primary := newRouter(false, &primaryPuts)
+			defer primary.Close()
+			replica := newRouter(false, &replicaPuts)
+			defer replica.Close()
+			failing := newRouter(true, &failingPuts)
+			defer failing.Close()
+
+			defaults := &MikrotikDefaults{DefaultTTL: 3600}
+			newClient := func(baseURL string) *MikrotikApiClient {
+				client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+					BaseUrl:       baseURL,
+					Username:      "testuser",
+					Password:      "testpass",
+					SkipTLSVerify: true,
+				}, defaults)
+				if err != nil {
+					t.Fatalf("Failed to create client: %v", err)
+				}
+				return client
+			}
+
+			provider := &MikrotikProvider{
+				client:       newClient(primary.URL),
+				replicas:     []DNSClient{newClient(replica.URL)},
+				defaults:     defaults,
+				domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+			}
+			if tt.failingReplica {
+				provider.replicas = append([]DNSClient{newClient(failing.URL)}, provider.replicas...)
+			}
+
+			err := provider.ApplyChanges(context.Background(), &plan.Changes{
+				Create: []*endpoint.Endpoint{
+					NewEndpoint("new.example.com", []string{"1.1.1.1"}, "A", 3600, nil),
+				},
+			})
+
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("Expected error, got none")
+				}
+				if !strings.Contains(err.Error(), failing.URL) {
+					t.Errorf("Expected error to name the failing router %s, got %v", failing.URL, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if primaryPuts != tt.expectedPutCall {
+				t.Errorf("Expected %d PUT calls on the primary, got %d", tt.expectedPutCall, primaryPuts)
+			}
+			if replicaPuts != tt.expectedPutCall {
+				t.Errorf("Expected %d PUT calls on the replica, got %d", tt.expectedPutCall, replicaPuts)
+			}
+		})
+	}
+}
+
+func TestMikrotikProvider_Zones(t *testing.T) {
+	// newRouter serves the given records and counts the records created on it
+	newRouter := func(records []DNSRecord, created *[]string) *httptest.Server {
+		return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case r.Method == http.MethodGet && r.URL.Path == "/rest/ip/dns/static":
+				if err := json.NewEncoder(w).Encode(records); err != nil {
+					t.Errorf("Failed to encode response: %v", err)
+				}
+			case r.Method == http.MethodPut && r.URL.Path == "/rest/ip/dns/static":
+				var record DNSRecord
+				if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+					t.Errorf("Failed to decode request: %v", err)
+				}
+				*created = append(*created, record.Name)
+				record.ID = "*new"
+				if err := json.NewEncoder(w).Encode(record); err != nil {
+					t.Errorf("Failed to encode response: %v", err)
+				}
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	}
+
+	var houseCreated, labCreated []string
+	house := newRouter([]DNSRecord{
+		{ID: "*1", Name: "nas.home.example.com", Type: "A", Address: "192.168.1.10", TTL: "1h"},
+		{ID: "*2", Name: "stale.lab.example.com", Type: "A", Address: "192.168.1.20", TTL: "1h"},
+	}, &houseCreated)
+	defer house.Close()
+	lab := newRouter([]DNSRecord{
+		{ID: "*1", Name: "k8s.lab.example.com", Type: "A", Address: "10.0.0.10", TTL: "1h"},
+		{ID: "*2", Name: "nas.home.example.com", Type: "A", Address: "10.0.0.20", TTL: "1h"},
+	}, &labCreated)
+	defer lab.Close()
+
+	defaults := &MikrotikDefaults{DefaultTTL: 3600}
+	newClient := func(baseURL string) *MikrotikApiClient {
+		client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+			BaseUrl:       baseURL,
+			Username:      "testuser",
+			Password:      "testpass",
+			SkipTLSVerify: true,
+		}, defaults)
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		return client
+	}
+
+	provider := &MikrotikProvider{
+		client: newClient(house.URL),
+		zones: sortZones([]*zoneRouter{
+			{zone: "lab.example.com", filter: endpoint.NewDomainFilter([]string{"lab.example.com"}), client: newClient(lab.URL)},
+		}),
+		defaults:     defaults,
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+	}
+
+	t.Run("Records are merged from the router owning each zone", func(t *testing.T) {
+		endpoints, err := provider.Records(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		got := map[string]string{}
+		for _, ep := range endpoints {
+			got[ep.DNSName] = ep.Targets[0]
+		}
+		expected := map[string]string{
+			"nas.home.example.com": "192.168.1.10",
+			"k8s.lab.example.com":  "10.0.0.10",
+		}
+		if len(got) != len(expected) {
+			t.Fatalf("Expected endpoints %v, got %v", expected, got)
+		}
+		for name, target := range expected {
+			if got[name] != target {
+				t.Errorf("Expected %s -> %s, got %s", name, target, got[name])
+			}
+		}
+	})
+
+	t.Run("Changes are dispatched to the router owning each zone", func(t *testing.T) {
+		err := provider.ApplyChanges(context.Background(), &plan.Changes{
+			Create: []*endpoint.Endpoint{
+				NewEndpoint("printer.home.example.com", []string{"192.168.1.30"}, "A", 3600, nil),
+				NewEndpoint("app.lab.example.com", []string{"10.0.0.30"}, "A", 3600, nil),
+			},
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if len(houseCreated) != 1 || houseCreated[0] != "printer.home.example.com" {
+			t.Errorf("Expected only printer.home.example.com on the house router, got %v", houseCreated)
+		}
+		if len(labCreated) != 1 || labCreated[0] != "app.lab.example.com" {
+			t.Errorf("Expected only app.lab.example.com on the lab router, got %v", labCreated)
+		}
+	})
+}
+
+func TestSortZones(t *testing.T) {
+	zones := sortZones([]*zoneRouter{
+		{zone: "example.com"},
+		{zone: "lab.example.com"},
+		{zone: "example.org"},
+	})
+
+	expected := []string{"lab.example.com", "example.com", "example.org"}
+	for i, zone := range zones {
+		if zone.zone != expected[i] {
+			t.Errorf("Expected zone %d to be %s, got %s", i, expected[i], zone.zone)
+		}
+	}
+}
+
+func TestNewMikrotikProviderWithClient(t *testing.T) {
+	client := NewMemoryDNSClient(
+		DNSRecord{Name: "old.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h"},
+		DNSRecord{Name: "other.org", Type: "A", Address: "2.2.2.2", TTL: "1h"},
+	)
+
+	p, err := NewMikrotikProviderWithClient(endpoint.NewDomainFilter([]string{"example.com"}), &MikrotikDefaults{DefaultTTL: 3600, DefaultComment: "external-dns"}, client)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{NewEndpoint("new.example.com", []string{"3.3.3.3", "4.4.4.4"}, "A", 0, nil)},
+		Delete: []*endpoint.Endpoint{NewEndpoint("old.example.com", []string{"1.1.1.1"}, "A", 3600, nil)},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	endpoints, err := p.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].DNSName != "new.example.com" || len(endpoints[0].Targets) != 2 {
+		t.Fatalf("Expected only new.example.com with 2 targets, got %v", endpoints)
+	}
+	if endpoints[0].RecordTTL != 3600 {
+		t.Errorf("Expected the default TTL to be applied, got %d", endpoints[0].RecordTTL)
+	}
+
+	if _, err := NewMikrotikProviderWithClient(nil, nil, nil); err == nil {
+		t.Errorf("Expected error without a client, got none")
+	}
+}
+
+func TestMikrotikProvider_ApplyChanges_InPlaceUpdates(t *testing.T) {
+	client := NewMemoryDNSClient(
+		DNSRecord{Name: "meta.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h", Comment: "old"},
+		DNSRecord{Name: "meta.example.com", Type: "A", Address: "2.2.2.2", TTL: "1h", Comment: "old"},
+		DNSRecord{Name: "target.example.com", Type: "A", Address: "3.3.3.3", TTL: "1h"},
+		DNSRecord{Name: "shrink.example.com", Type: "A", Address: "4.4.4.4", TTL: "1h"},
+		DNSRecord{Name: "shrink.example.com", Type: "A", Address: "5.5.5.5", TTL: "1h"},
+	)
+	idsBefore := map[string]string{}
+	for _, record := range client.Records() {
+		idsBefore[record.Name+"/"+record.Address] = record.ID
+	}
+
+	p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{DefaultTTL: 3600}, client)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			NewEndpoint("meta.example.com", []string{"1.1.1.1", "2.2.2.2"}, "A", 3600, []map[string]string{{"comment": "old"}}),
+			NewEndpoint("target.example.com", []string{"3.3.3.3"}, "A", 3600, nil),
+			NewEndpoint("shrink.example.com", []string{"4.4.4.4", "5.5.5.5"}, "A", 3600, nil),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			NewEndpoint("meta.example.com", []string{"1.1.1.1", "2.2.2.2"}, "A", 60, []map[string]string{{"comment": "new"}}),
+			NewEndpoint("target.example.com", []string{"6.6.6.6"}, "A", 3600, nil),
+			NewEndpoint("shrink.example.com", []string{"5.5.5.5"}, "A", 3600, nil),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	records := client.Records()
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 records left, got %+v", records)
+	}
+	for _, record := range records {
+		switch record.Name {
+		case "meta.example.com":
+			if record.ID != idsBefore[record.Name+"/"+record.Address] || record.Comment != "new" || record.TTL != "1m" {
+				t.Errorf("Expected the record to be updated in place, got %+v", record)
+			}
+		case "target.example.com":
+			if record.ID != idsBefore["target.example.com/3.3.3.3"] || record.Address != "6.6.6.6" {
+				t.Errorf("Expected the target to be updated in place, got %+v", record)
+			}
+		case "shrink.example.com":
+			if record.ID != idsBefore["shrink.example.com/5.5.5.5"] {
+				t.Errorf("Expected the remaining target to be left untouched, got %+v", record)
+			}
+		}
+	}
+}
+
+func TestMikrotikProvider_ApplyChanges_Idempotent(t *testing.T) {
+	// A previous attempt created one of the records before timing out
+	client := NewMemoryDNSClient(
+		DNSRecord{Name: "a.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h"},
+	)
+	existingID := client.Records()[0].ID
+
+	p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{DefaultTTL: 3600}, client)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{NewEndpoint("a.example.com", []string{"1.1.1.1", "2.2.2.2"}, "A", 60, nil)},
+		Delete: []*endpoint.Endpoint{NewEndpoint("gone.example.com", []string{"3.3.3.3"}, "A", 3600, nil)},
+	}
+	for attempt := 1; attempt <= 2; attempt++ {
+		if err := p.ApplyChanges(context.Background(), changes); err != nil {
+			t.Fatalf("Expected no error on attempt %d, got %v", attempt, err)
+		}
+
+		records := client.Records()
+		if got := addresses(records); !slices.Equal(got, []string{"a.example.com=1.1.1.1", "a.example.com=2.2.2.2"}) {
+			t.Fatalf("Expected no duplicate records after attempt %d, got %v", attempt, got)
+		}
+		for _, record := range records {
+			if record.Address == "1.1.1.1" && (record.ID != existingID || record.TTL != "1m") {
+				t.Errorf("Expected the existing record to be updated in place, got %+v", record)
+			}
+		}
+	}
+}
+
+// orderRecordingClient records the order of bulk creations and deletions, optionally failing creations
+type orderRecordingClient struct {
+	*MemoryDNSClient
+	calls      []string
+	failCreate bool
+}
+
+func (c *orderRecordingClient) CreateDNSRecords(ctx context.Context, records []*DNSRecord) ([]*DNSRecord, error) {
+	for _, record := range records {
+		c.calls = append(c.calls, "create "+record.Address)
+	}
+	if c.failCreate {
+		return make([]*DNSRecord, len(records)), fmt.Errorf("creation failed")
+	}
+	return c.MemoryDNSClient.CreateDNSRecords(ctx, records)
+}
+
+func (c *orderRecordingClient) DeleteDNSRecords(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		for _, record := range c.Records() {
+			if record.ID == id {
+				c.calls = append(c.calls, "delete "+record.Address)
+			}
+		}
+	}
+	return c.MemoryDNSClient.DeleteDNSRecords(ctx, ids)
+}
+
+func TestMikrotikProvider_ApplyChanges_MakeBeforeBreak(t *testing.T) {
+	testCases := []struct {
+		name              string
+		makeBeforeBreak   bool
+		failCreate        bool
+		expectedCalls     []string
+		expectedAddresses []string
+	}{
+		{
+			name:              "deletes come first by default",
+			expectedCalls:     []string{"delete 1.1.1.1", "delete 3.3.3.3", "create 4.4.4.4", "create 2.2.2.2"},
+			expectedAddresses: []string{"2.2.2.2", "4.4.4.4"},
+		},
+		{
+			name:              "replaced records are deleted after their replacements are created",
+			makeBeforeBreak:   true,
+			expectedCalls:     []string{"delete 3.3.3.3", "create 4.4.4.4", "create 2.2.2.2", "delete 1.1.1.1"},
+			expectedAddresses: []string{"2.2.2.2", "4.4.4.4"},
+		},
+		{
+			name:              "replaced records are kept if their replacements cannot be created",
+			makeBeforeBreak:   true,
+			failCreate:        true,
+			expectedCalls:     []string{"delete 3.3.3.3", "create 4.4.4.4", "create 2.2.2.2"},
+			expectedAddresses: []string{"1.1.1.1", "3.3.3.3"}, // the deletion is rolled back
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &orderRecordingClient{
+				MemoryDNSClient: NewMemoryDNSClient(
+					DNSRecord{Name: "update.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h"},
+					DNSRecord{Name: "delete.example.com", Type: "A", Address: "3.3.3.3", TTL: "1h"},
+				),
+				failCreate: tc.failCreate,
+			}
+
+			p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{DefaultTTL: 3600}, client)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			p.(*MikrotikProvider).makeBeforeBreak = tc.makeBeforeBreak
+
+			// Switching the record to a regexp cannot be done in place
+			err = p.ApplyChanges(context.Background(), &plan.Changes{
+				Create:    []*endpoint.Endpoint{NewEndpoint("create.example.com", []string{"4.4.4.4"}, "A", 3600, nil)},
+				UpdateOld: []*endpoint.Endpoint{NewEndpoint("update.example.com", []string{"1.1.1.1"}, "A", 3600, nil)},
+				UpdateNew: []*endpoint.Endpoint{NewEndpoint("update.example.com", []string{"2.2.2.2"}, "A", 3600, []map[string]string{{"regexp": `.*\.update\.example\.com`}})},
+				Delete:    []*endpoint.Endpoint{NewEndpoint("delete.example.com", []string{"3.3.3.3"}, "A", 3600, nil)},
+			})
+			if tc.failCreate != (err != nil) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !slices.Equal(client.calls, tc.expectedCalls) {
+				t.Errorf("Expected calls %v, got %v", tc.expectedCalls, client.calls)
+			}
+			var addresses []string
+			for _, record := range client.Records() {
+				addresses = append(addresses, record.Address)
+			}
+			slices.Sort(addresses)
+			if !slices.Equal(addresses, tc.expectedAddresses) {
+				t.Errorf("Expected records %v, got %v", tc.expectedAddresses, addresses)
+			}
+		})
+	}
+}
+
+func TestMikrotikProvider_ApplyChanges_Validation(t *testing.T) {
+	client := &orderRecordingClient{
+		MemoryDNSClient: NewMemoryDNSClient(
+			DNSRecord{Name: "update.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h"},
+			DNSRecord{Name: "delete.example.com", Type: "A", Address: "2.2.2.2", TTL: "1h"},
+		),
+	}
+
+	p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{DefaultTTL: 3600}, client)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			NewEndpoint("good.example.com", []string{"3.3.3.3"}, "A", 3600, nil),
+			NewEndpoint("bad-ip.example.com", []string{"300.1.1.1"}, "A", 3600, nil),
+			NewEndpoint("bad-srv.example.com", []string{"10 5"}, "SRV", 3600, nil),
+			NewEndpoint("bad-type.example.com", []string{"whatever"}, "PTR", 3600, nil),
+		},
+		UpdateOld: []*endpoint.Endpoint{NewEndpoint("update.example.com", []string{"1.1.1.1"}, "A", 3600, nil)},
+		UpdateNew: []*endpoint.Endpoint{NewEndpoint("update.example.com", []string{"1.1.1"}, "A", 3600, nil)},
+		Delete:    []*endpoint.Endpoint{NewEndpoint("delete.example.com", []string{"2.2.2.2"}, "A", 3600, nil)},
+	})
+	if err == nil {
+		t.Fatalf("Expected the change set to be rejected")
+	}
+
+	for _, name := range []string{"bad-ip.example.com", "bad-srv.example.com", "bad-type.example.com", "update.example.com"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected the error to list %s, got %v", name, err)
+		}
+	}
+	if strings.Contains(err.Error(), "good.example.com") {
+		t.Errorf("Expected the error not to list valid endpoints, got %v", err)
+	}
+	var endpointErr *EndpointError
+	if !errors.As(err, &endpointErr) {
+		t.Errorf("Expected an EndpointError, got %v", err)
+	}
+
+	if len(client.calls) != 0 || len(client.Records()) != 2 {
+		t.Errorf("Expected the router to be left untouched, got calls %v and records %+v", client.calls, client.Records())
+	}
+}
+
+func TestMikrotikProvider_ApplyChanges_ContinueOnError(t *testing.T) {
+	client := NewMemoryDNSClient(
+		DNSRecord{Name: "update.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h"},
+		DNSRecord{Name: "broken.example.com", Type: "A", Address: "2.2.2.2", TTL: "1h"},
+	)
+
+	p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{DefaultTTL: 3600}, client)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	p.(*MikrotikProvider).continueOnError = true
+
+	skippedBefore := testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues("A"))
+
+	invalid := NewEndpoint("bad-ip.example.com", []string{"300.1.1.1"}, "A", 3600, nil)
+	invalid.Labels = endpoint.Labels{endpoint.ResourceLabelKey: "service/default/bad-ip"}
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			NewEndpoint("good.example.com", []string{"3.3.3.3"}, "A", 3600, nil),
+			invalid,
+		},
+		UpdateOld: []*endpoint.Endpoint{
+			NewEndpoint("update.example.com", []string{"1.1.1.1"}, "A", 3600, nil),
+			NewEndpoint("broken.example.com", []string{"2.2.2.2"}, "A", 3600, nil),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			NewEndpoint("update.example.com", []string{"4.4.4.4"}, "A", 3600, nil),
+			NewEndpoint("broken.example.com", []string{"2.2.2"}, "A", 3600, nil),
+		},
+	})
+	var skippedErr *SkippedEndpointsError
+	if !errors.As(err, &skippedErr) || err != error(skippedErr) {
+		t.Fatalf("Expected only a SkippedEndpointsError, got %v", err)
+	}
+	if skippedErr.SkippedEndpoints() != 2 || !strings.Contains(err.Error(), "skipped 2 invalid endpoints") ||
+		!strings.Contains(err.Error(), "bad-ip.example.com") || !strings.Contains(err.Error(), "broken.example.com") {
+		t.Errorf("Expected a summary of the skipped endpoints, got %v", err)
+	}
+
+	if skipped := testutil.ToFloat64(skippedEndpointsTotal.WithLabelValues("A")) - skippedBefore; skipped != 2 {
+		t.Errorf("Expected 2 skipped endpoints to be counted, got %v", skipped)
+	}
+
+	var records []string
+	for _, record := range client.Records() {
+		records = append(records, record.Name+"="+record.Address)
+	}
+	slices.Sort(records)
+	expected := []string{"broken.example.com=2.2.2.2", "good.example.com=3.3.3.3", "update.example.com=4.4.4.4"}
+	if !slices.Equal(records, expected) {
+		t.Errorf("Expected records %v, got %v", expected, records)
+	}
+}
+
+// blockingClient blocks record fetches until released, holds creations until told to proceed, and tracks how many
+// creations run concurrently
+type blockingClient struct {
+	*MemoryDNSClient
+	fetching   chan struct{}
+	release    chan struct{}
+	fetches    atomic.Int32
+	creating   chan struct{}
+	proceed    chan struct{}
+	running    atomic.Int32
+	overlapped atomic.Bool
+}
+
+func (c *blockingClient) GetDNSRecords(ctx context.Context, filter DNSRecordFilter) ([]DNSRecord, error) {
+	if c.release != nil && filter == (DNSRecordFilter{}) {
+		c.fetches.Add(1)
+		c.fetching <- struct{}{}
+		<-c.release
+	}
+	return c.MemoryDNSClient.GetDNSRecords(ctx, filter)
+}
+
+func (c *blockingClient) CreateDNSRecords(ctx context.Context, records []*DNSRecord) ([]*DNSRecord, error) {
+	if c.running.Add(1) > 1 {
+		c.overlapped.Store(true)
+	}
+	defer c.running.Add(-1)
+	if c.creating != nil {
+		c.creating <- struct{}{}
+		<-c.proceed
+	}
+	return c.MemoryDNSClient.CreateDNSRecords(ctx, records)
+}
+
+// waitingContext signals the first time its Done channel is asked for. Records only waits on its context once it
+// joined the fetch in progress, or started a new one.
+type waitingContext struct {
+	context.Context
+	waiting chan<- struct{}
+	once    sync.Once
+}
+
+func (c *waitingContext) Done() <-chan struct{} {
+	c.once.Do(func() { c.waiting <- struct{}{} })
+	return c.Context.Done()
+}
+
+func TestMikrotikProvider_Records_Coalesced(t *testing.T) {
+	client := &blockingClient{
+		MemoryDNSClient: NewMemoryDNSClient(DNSRecord{Name: "host.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h"}),
+		fetching:        make(chan struct{}, 1),
+		release:         make(chan struct{}),
+	}
+	p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{}, client)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	sharedBefore := testutil.ToFloat64(recordsFetchesSharedTotal)
+
+	var wg sync.WaitGroup
+	results := make([][]*endpoint.Endpoint, 5)
+	errs := make([]error, len(results))
+	waiting := make(chan struct{}, len(results)-1)
+	for i := range results {
+		ctx := context.Background()
+		if i > 0 {
+			ctx = &waitingContext{Context: ctx, waiting: waiting}
+		}
+		wg.Go(func() {
+			results[i], errs[i] = p.Records(ctx)
+		})
+		if i == 0 {
+			<-client.fetching
+		}
+	}
+	// Let the fetch complete once every other call joined it
+	for range len(results) - 1 {
+		<-waiting
+	}
+	close(client.release)
+	wg.Wait()
+
+	if fetches := client.fetches.Load(); fetches != 1 {
+		t.Errorf("Expected concurrent calls to share a single fetch, got %d fetches", fetches)
+	}
+	if shared := testutil.ToFloat64(recordsFetchesSharedTotal) - sharedBefore; shared != 4 {
+		t.Errorf("Expected 4 shared fetches to be counted, got %v", shared)
+	}
+	for i, endpoints := range results {
+		if errs[i] != nil {
+			t.Errorf("Expected call %d to succeed, got %v", i, errs[i])
+		}
+		if len(endpoints) != 1 || endpoints[0].DNSName != "host.example.com" {
+			t.Errorf("Expected call %d to return the records, got %+v", i, endpoints)
+		}
+	}
+
+	// A cancelled call does not fail the fetch it joined
+	client.release = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-client.fetching
+		cancel()
+	}()
+	if _, err := p.Records(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancelled call to return its context error, got %v", err)
+	}
+	close(client.release)
+}
+
+func TestMikrotikProvider_ApplyChanges_Serialized(t *testing.T) {
+	client := &blockingClient{
+		MemoryDNSClient: NewMemoryDNSClient(),
+		creating:        make(chan struct{}),
+		proceed:         make(chan struct{}),
+	}
+	p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{}, client)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Go(func() {
+			errs[i] = p.ApplyChanges(context.Background(), &plan.Changes{
+				Create: []*endpoint.Endpoint{NewEndpoint(fmt.Sprintf("host%d.example.com", i), []string{"1.1.1.1"}, "A", 3600, nil)},
+			})
+		})
+	}
+	// Each creation is held until the next one is let through, so overlapping change sets would be caught
+	for range errs {
+		<-client.creating
+		client.proceed <- struct{}{}
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Expected change set %d to be applied, got %v", i, err)
+		}
+	}
+	if client.overlapped.Load() {
+		t.Errorf("Expected change sets to be applied one at a time")
+	}
+	if len(client.Records()) != 5 {
+		t.Errorf("Expected 5 records, got %+v", client.Records())
+	}
+
+	// A call giving up while waiting does not apply its changes
+	p.(*MikrotikProvider).applyMu.Lock()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- p.ApplyChanges(ctx, &plan.Changes{
+			Create: []*endpoint.Endpoint{NewEndpoint("late.example.com", []string{"1.1.1.1"}, "A", 3600, nil)},
+		})
+	}()
+	cancel()
+	p.(*MikrotikProvider).applyMu.Unlock()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancelled call to give up, got %v", err)
+	}
+	if len(client.Records()) != 5 {
+		t.Errorf("Expected the cancelled change set not to be applied, got %+v", client.Records())
+	}
+}
+
+// unreachableClient fails to fetch records while unreachable is set
+type unreachableClient struct {
+	*MemoryDNSClient
+	unreachable bool
+}
+
+func (c *unreachableClient) GetDNSRecords(ctx context.Context, filter DNSRecordFilter) ([]DNSRecord, error) {
+	if c.unreachable {
+		return nil, fmt.Errorf("dial tcp: connection refused")
+	}
+	return c.MemoryDNSClient.GetDNSRecords(ctx, filter)
+}
+
+func TestMikrotikProvider_Records_StaleOnError(t *testing.T) {
+	ctx := context.Background()
+	client := &unreachableClient{MemoryDNSClient: NewMemoryDNSClient(DNSRecord{Name: "host.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h"})}
+	changes := &plan.Changes{Create: []*endpoint.Endpoint{NewEndpoint("new.example.com", []string{"2.2.2.2"}, "A", 3600, nil)}}
+
+	p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{}, client)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Disabled by default
+	client.unreachable = true
+	if _, err := p.Records(ctx); err == nil {
+		t.Fatalf("Expected an error without stale records enabled")
+	}
+
+	p.(*MikrotikProvider).staleRecordsMaxAge = time.Minute
+	client.unreachable = false
+	if _, err := p.Records(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The last records fetched are served while the router is unreachable, and changes are refused
+	client.unreachable = true
+	endpoints, err := p.Records(ctx)
+	if err != nil || len(endpoints) != 1 || endpoints[0].DNSName != "host.example.com" {
+		t.Fatalf("Expected the last records fetched, got %+v and %v", endpoints, err)
+	}
+	if stale := testutil.ToFloat64(staleRecordsGauge); stale != 1 {
+		t.Errorf("Expected the stale records gauge to be 1, got %v", stale)
+	}
+	if err := p.ApplyChanges(ctx, changes); !errors.Is(err, ErrStaleRecords) {
+		t.Errorf("Expected changes to be refused, got %v", err)
+	}
+
+	// Records too old are not served
+	mp := p.(*MikrotikProvider)
+	mp.lastRecordsMu.Lock()
+	mp.lastRecordsAt = mp.lastRecordsAt.Add(-mp.staleRecordsMaxAge)
+	mp.lastRecordsMu.Unlock()
+	if _, err := p.Records(ctx); err == nil {
+		t.Errorf("Expected an error once the last records fetched are too old")
+	}
+
+	// Changes are accepted again once the router can be reached
+	client.unreachable = false
+	if _, err := p.Records(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stale := testutil.ToFloat64(staleRecordsGauge); stale != 0 {
+		t.Errorf("Expected the stale records gauge to be 0, got %v", stale)
+	}
+	if err := p.ApplyChanges(ctx, changes); err != nil {
+		t.Errorf("Expected changes to be applied, got %v", err)
+	}
+}
+
+func TestMikrotikProvider_ApplyChanges_DeleteConflicts(t *testing.T) {
+	tests := []struct {
+		name           string
+		checkConflicts bool
+		expectedLeft   []string
+	}{
+		{
+			name:           "Changed records are deleted without the check",
+			checkConflicts: false,
+			expectedLeft:   nil,
+		},
+		{
+			name:           "Changed records are kept with the check",
+			checkConflicts: true,
+			expectedLeft:   []string{"changed.example.com=2.2.2.2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			inner := NewMemoryDNSClient(
+				DNSRecord{Name: "same.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h", Comment: "external-dns"},
+				DNSRecord{Name: "changed.example.com", Type: "A", Address: "2.2.2.2", TTL: "1h", Comment: "external-dns"},
+			)
+
+			p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{DefaultTTL: 3600}, withSnapshot(inner, time.Minute))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			p.(*MikrotikProvider).checkDeleteConflicts = tt.checkConflicts
+
+			endpoints, err := p.Records(ctx)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			// An admin edits one of the records after external-dns read it, unbeknownst to the snapshot
+			for _, record := range inner.Records() {
+				if record.Name == "changed.example.com" {
+					if _, err := inner.UpdateDNSRecord(ctx, record.ID, map[string]string{"comment": "edited in WinBox"}); err != nil {
+						t.Fatalf("Expected no error, got %v", err)
+					}
+				}
+			}
+
+			err = p.ApplyChanges(ctx, &plan.Changes{Delete: endpoints})
+			var conflict *DeleteConflictError
+			if tt.checkConflicts {
+				if !errors.As(err, &conflict) {
+					t.Fatalf("Expected a delete conflict, got %v", err)
+				}
+				if conflict.Record.Name != "changed.example.com" || !slices.Equal(conflict.Fields, []string{"comment"}) {
+					t.Errorf("Expected a conflict on the comment of changed.example.com, got %v", conflict)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if got := addresses(inner.Records()); !slices.Equal(got, tt.expectedLeft) {
+				t.Errorf("Expected records %v to be left, got %v", tt.expectedLeft, got)
 			}
 		})
 	}
 }
+
+func TestMikrotikProvider_ApplyChanges_DeleteConflictsOnUpdate(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldEndpoint func(read *endpoint.Endpoint) *endpoint.Endpoint
+	}{
+		{
+			name:        "Endpoint as read from the router",
+			oldEndpoint: func(read *endpoint.Endpoint) *endpoint.Endpoint { return read },
+		},
+		{
+			name: "Endpoint relying on the defaults",
+			oldEndpoint: func(read *endpoint.Endpoint) *endpoint.Endpoint {
+				return endpoint.NewEndpoint(read.DNSName, read.RecordType, read.Targets...)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			inner := NewMemoryDNSClient(
+				DNSRecord{Name: "a.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h", Comment: "external-dns"},
+				DNSRecord{Name: "a.example.com", Type: "A", Address: "2.2.2.2", TTL: "1h", Comment: "external-dns"},
+			)
+
+			p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{DefaultTTL: 3600, DefaultComment: "external-dns"}, inner)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			p.(*MikrotikProvider).checkDeleteConflicts = true
+
+			endpoints, err := p.Records(ctx)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(endpoints) != 1 {
+				t.Fatalf("Expected 1 endpoint, got %v", endpoints)
+			}
+
+			oldEndpoint := tt.oldEndpoint(endpoints[0])
+			newEndpoint := withTargets(oldEndpoint, "1.1.1.1")
+			err = p.ApplyChanges(ctx, &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{oldEndpoint},
+				UpdateNew: []*endpoint.Endpoint{newEndpoint},
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			expected := []string{"a.example.com=1.1.1.1"}
+			if got := addresses(inner.Records()); !slices.Equal(got, expected) {
+				t.Errorf("Expected records %v to be left, got %v", expected, got)
+			}
+		})
+	}
+}
+
+func TestMikrotikProvider_FilterChanges_LeavesChangesUntouched(t *testing.T) {
+	p := &MikrotikProvider{defaults: &MikrotikDefaults{}}
+
+	// Spare capacity lets an append write into the caller's backing array
+	create := make([]*endpoint.Endpoint, 1, 2)
+	create[0] = NewEndpoint("create.example.com", []string{"1.1.1.1"}, "A", 3600, nil)
+	changes := &plan.Changes{
+		Create:    create,
+		UpdateNew: []*endpoint.Endpoint{NewEndpoint("update.example.com", []string{"2.2.2.2"}, "A", 3600, nil)},
+	}
+
+	filtered, err := p.filterChanges(changes)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(filtered.Create) != 2 {
+		t.Errorf("Expected 2 endpoints to create, got %v", filtered.Create)
+	}
+	if len(changes.Create) != 1 || create[:2][1] != nil {
+		t.Errorf("Expected the changes passed in to be left untouched, got %v", create[:2])
+	}
+}
@@ -1,6 +1,7 @@
 package mikrotik
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"regexp"
@@ -40,6 +41,10 @@ type DNSRecord struct {
 
 	// Additional fields for other record types that are not currently supported
 	// ForwardTo    string `json:"forward-to,omitempty"`    // FWD
+
+	// takenOver is the record as it was on the router, when an identical record was taken over instead of
+	// creating this one. It is never sent to the router.
+	takenOver *DNSRecord
 }
 
 // NewDNSRecords converts an ExternalDNS Endpoint to multiple Mikrotik DNSRecords (one per target)
@@ -97,52 +102,8 @@ func NewDNSRecords(ep *endpoint.Endpoint) ([]*DNSRecord, error) {
 
 		// Create a new record by copying the base record
 		record := baseRecord
-
-		// Set target-specific fields based on record type
-		switch record.Type {
-		case "A":
-			if err := validateIPv4(target); err != nil {
-				return nil, fmt.Errorf("invalid A record target %s: %w", target, err)
-			}
-			record.Address = target
-		case "AAAA":
-			if err := validateIPv6(target); err != nil {
-				return nil, fmt.Errorf("invalid AAAA record target %s: %w", target, err)
-			}
-			record.Address = target
-		case "CNAME":
-			if err := validateDomain(target); err != nil {
-				return nil, fmt.Errorf("invalid CNAME record target %s: %w", target, err)
-			}
-			record.CName = target
-		case "TXT":
-			if err := validateTXT(target); err != nil {
-				return nil, fmt.Errorf("invalid TXT record target %s: %w", target, err)
-			}
-			record.Text = target
-		case "MX":
-			preference, exchange, err := parseMX(target)
-			if err != nil {
-				return nil, fmt.Errorf("invalid MX record target %s: %w", target, err)
-			}
-			record.MXPreference = preference
-			record.MXExchange = exchange
-		case "SRV":
-			priority, weight, port, srvTarget, err := parseSRV(target)
-			if err != nil {
-				return nil, fmt.Errorf("invalid SRV record target %s: %w", target, err)
-			}
-			record.SrvPriority = priority
-			record.SrvWeight = weight
-			record.SrvPort = port
-			record.SrvTarget = srvTarget
-		case "NS":
-			if err := validateDomain(target); err != nil {
-				return nil, fmt.Errorf("invalid NS record target %s: %w", target, err)
-			}
-			record.NS = target
-		default:
-			return nil, fmt.Errorf("unsupported DNS type: %s", ep.RecordType)
+		if err := record.setTarget(target); err != nil {
+			return nil, err
 		}
 
 		records = append(records, &record)
@@ -156,6 +117,57 @@ func NewDNSRecords(ep *endpoint.Endpoint) ([]*DNSRecord, error) {
 	return records, nil
 }
 
+// setTarget sets the target-specific fields of the record from an ExternalDNS target string, based on its type
+func (r *DNSRecord) setTarget(target string) error {
+	switch r.Type {
+	case "A":
+		if err := validateIPv4(target); err != nil {
+			return fmt.Errorf("invalid A record target %s: %w", target, err)
+		}
+		r.Address = target
+	case "AAAA":
+		if err := validateIPv6(target); err != nil {
+			return fmt.Errorf("invalid AAAA record target %s: %w", target, err)
+		}
+		r.Address = target
+	case "CNAME":
+		if err := validateDomain(target); err != nil {
+			return fmt.Errorf("invalid CNAME record target %s: %w", target, err)
+		}
+		r.CName = target
+	case "TXT":
+		if err := validateTXT(target); err != nil {
+			return fmt.Errorf("invalid TXT record target %s: %w", target, err)
+		}
+		r.Text = target
+	case "MX":
+		preference, exchange, err := parseMX(target)
+		if err != nil {
+			return fmt.Errorf("invalid MX record target %s: %w", target, err)
+		}
+		r.MXPreference = preference
+		r.MXExchange = exchange
+	case "SRV":
+		priority, weight, port, srvTarget, err := parseSRV(target)
+		if err != nil {
+			return fmt.Errorf("invalid SRV record target %s: %w", target, err)
+		}
+		r.SrvPriority = priority
+		r.SrvWeight = weight
+		r.SrvPort = port
+		r.SrvTarget = srvTarget
+	case "NS":
+		if err := validateDomain(target); err != nil {
+			return fmt.Errorf("invalid NS record target %s: %w", target, err)
+		}
+		r.NS = target
+	default:
+		return fmt.Errorf("unsupported DNS type: %s", r.Type)
+	}
+
+	return nil
+}
+
 // toExternalDNSTarget converts a Mikrotik DNSRecord to an ExternalDNS target string
 func (r *DNSRecord) toExternalDNSTarget() (string, error) {
 	log.Debugf("Converting MikrotikDNS record to ExternalDNS target: %+v", r)
@@ -214,6 +226,97 @@ func (r *DNSRecord) toExternalDNSTarget() (string, error) {
 	}
 }
 
+// sameAs reports whether the record has the same name or regexp, type and target as the other one,
+// whatever its other fields
+func (r *DNSRecord) sameAs(other DNSRecord) bool {
+	if r.Type != other.Type || r.Name != other.Name || r.Regexp != other.Regexp {
+		return false
+	}
+
+	target, err := r.toExternalDNSTarget()
+	if err != nil {
+		return false
+	}
+	otherTarget, err := other.toExternalDNSTarget()
+	return err == nil && target == otherTarget
+}
+
+// fields returns the non-empty fields of the record, as named by the RouterOS API
+func (r *DNSRecord) fields() (map[string]string, error) {
+	jsonRecord, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling DNS record: %w", err)
+	}
+
+	var fields map[string]string
+	if err := json.Unmarshal(jsonRecord, &fields); err != nil {
+		return nil, fmt.Errorf("error marshalling DNS record: %w", err)
+	}
+	for key, value := range fields {
+		if value == "" {
+			delete(fields, key)
+		}
+	}
+
+	return fields, nil
+}
+
+// changedFields returns the fields to set on the record for it to match the desired one. Fields
+// identifying the record (ID, name, type and regexp) are never changed. Fields only set on the record
+// are cleared, and a TTL of zero on the desired record leaves the TTL untouched.
+func (r *DNSRecord) changedFields(desired *DNSRecord) (map[string]string, error) {
+	current, err := r.fields()
+	if err != nil {
+		return nil, err
+	}
+	target, err := desired.fields()
+	if err != nil {
+		return nil, err
+	}
+
+	// Boolean fields are reported by RouterOS even when they have their default value
+	for _, key := range []string{"disabled", "match-subdomain"} {
+		for _, fields := range []map[string]string{current, target} {
+			if fields[key] == "" {
+				fields[key] = "false"
+			}
+		}
+	}
+
+	for key := range current {
+		if _, ok := target[key]; !ok {
+			target[key] = ""
+		}
+	}
+
+	changed := map[string]string{}
+	for key, value := range target {
+		switch key {
+		case ".id", "name", "type", "regexp":
+			continue
+		case "ttl":
+			if value == "" {
+				continue
+			}
+			targetTTL, err := MikrotikTTLtoEndpointTTL(value)
+			if err != nil || targetTTL == 0 {
+				continue
+			}
+			if current[key] != "" {
+				if currentTTL, err := MikrotikTTLtoEndpointTTL(current[key]); err == nil && currentTTL == targetTTL {
+					continue
+				}
+			}
+		}
+
+		if current[key] != value {
+			changed[key] = value
+		}
+	}
+
+	return changed, nil
+}
+
 // ================================================================================================
 // UTILS
 // ================================================================================================
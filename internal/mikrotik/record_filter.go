@@ -1,11 +1,64 @@
 package mikrotik
 
-import "net/url"
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// managedRecordTypes lists the record types fetched when the filter does not specify any
+const managedRecordTypes = "A,AAAA,CNAME,TXT,MX,SRV,NS"
+
+// dnsRecordColumns lists the columns held by DNSRecord, as named by the RouterOS API
+var dnsRecordColumns = []string{
+	".id", "name", "type", "ttl", "comment", "regexp", "match-subdomain", "address-list", "disabled",
+	"address", "cname", "text", "mx-exchange", "mx-preference", "srv-port", "srv-target", "srv-priority", "srv-weight", "ns",
+}
+
+// dnsRecordTargetColumns lists the columns identifying a record and its target
+var dnsRecordTargetColumns = []string{
+	".id", "name", "type", "regexp",
+	"address", "cname", "text", "mx-exchange", "mx-preference", "srv-port", "srv-target", "srv-priority", "srv-weight", "ns",
+}
+
+// recordColumns selects the columns of the records fetched from the router, through .proplist
+type recordColumns int
+
+const (
+	// allColumns fetches every column held by DNSRecord
+	allColumns recordColumns = iota
+	// targetColumns only fetches the ID, name, type and target of the records, enough to find the ones to delete
+	targetColumns
+)
+
+// proplist returns the names of the columns to fetch
+func (c recordColumns) proplist() []string {
+	if c == targetColumns {
+		return dnsRecordTargetColumns
+	}
+	return dnsRecordColumns
+}
+
+func (c recordColumns) String() string {
+	if c == targetColumns {
+		return "targets"
+	}
+	return "all"
+}
 
 // DNSRecordFilter represents the filtering criteria for DNS records in MikroTik RouterOS.
 type DNSRecordFilter struct {
 	Name string
 	Type string
+	// Target is an ExternalDNS target string, such as "10 mail.example.com" for an MX record.
+	// It requires a single Type. QueryDNSRecords filters it server-side, while GetDNSRecords fetches every
+	// record with the name and type and filters the target client-side.
+	Target string
+	// Columns selects the columns fetched, every column by default. Records fetched with fewer columns
+	// must not be compared with other records or created again.
+	Columns recordColumns
 }
 
 // toQueryParams converts a DNSRecordFilter to a query string for the RouterOS API.
@@ -13,7 +66,7 @@ type DNSRecordFilter struct {
 func (f DNSRecordFilter) toQueryParams() string {
 	recordType := f.Type
 	if recordType == "" {
-		recordType = "A,AAAA,CNAME,TXT,MX,SRV,NS"
+		recordType = managedRecordTypes
 	}
 
 	query := "type=" + recordType
@@ -22,5 +75,97 @@ func (f DNSRecordFilter) toQueryParams() string {
 		query += "&name=" + url.QueryEscape(f.Name)
 	}
 
+	query += "&.proplist=" + strings.Join(f.Columns.proplist(), ",")
+
 	return query
 }
+
+// matches tells whether a record matches the filter, as the RouterOS API would
+func (f DNSRecordFilter) matches(record DNSRecord) bool {
+	recordTypes := f.Type
+	if recordTypes == "" {
+		recordTypes = managedRecordTypes
+	}
+	if !slices.Contains(strings.Split(recordTypes, ","), record.Type) {
+		return false
+	}
+
+	if f.Name != "" && record.Name != f.Name {
+		return false
+	}
+	if f.Target != "" {
+		target, err := record.toExternalDNSTarget()
+		return err == nil && target == f.Target
+	}
+	return true
+}
+
+// queryWords converts the filter to RouterOS query words, leaving a single condition on the query stack
+func (f DNSRecordFilter) queryWords() ([]string, error) {
+	recordTypes := f.Type
+	if recordTypes == "" {
+		recordTypes = managedRecordTypes
+	}
+
+	var words []string
+	types := strings.Split(recordTypes, ",")
+	for _, recordType := range types {
+		words = append(words, "type="+recordType)
+	}
+	words = appendQueryOperator(words, "#|", len(types))
+
+	conditions := 1
+	if f.Name != "" {
+		words = append(words, "name="+f.Name)
+		conditions++
+	}
+	if f.Target != "" {
+		if len(types) != 1 {
+			return nil, fmt.Errorf("filtering on target %q requires a single record type, got %q", f.Target, recordTypes)
+		}
+		record := DNSRecord{Type: f.Type}
+		if err := record.setTarget(f.Target); err != nil {
+			return nil, err
+		}
+		fields, err := record.fields()
+		if err != nil {
+			return nil, err
+		}
+		delete(fields, "type")
+		for _, key := range slices.Sorted(maps.Keys(fields)) {
+			words = append(words, key+"="+fields[key])
+			conditions++
+		}
+	}
+
+	return appendQueryOperator(words, "#&", conditions), nil
+}
+
+// queryColumns returns the columns to fetch for the filters, every column unless none of them needs more than targets
+func queryColumns(filters []DNSRecordFilter) recordColumns {
+	if slices.ContainsFunc(filters, func(filter DNSRecordFilter) bool { return filter.Columns == allColumns }) {
+		return allColumns
+	}
+	return targetColumns
+}
+
+// dnsRecordQuery builds the .query of a print command returning the records matching any of the filters
+func dnsRecordQuery(filters []DNSRecordFilter) ([]string, error) {
+	var words []string
+	for _, filter := range filters {
+		filterWords, err := filter.queryWords()
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, filterWords...)
+	}
+	return appendQueryOperator(words, "#|", len(filters)), nil
+}
+
+// appendQueryOperator appends the operator needed to combine the last count conditions of the query stack into one
+func appendQueryOperator(words []string, operator string, count int) []string {
+	for range count - 1 {
+		words = append(words, operator)
+	}
+	return words
+}
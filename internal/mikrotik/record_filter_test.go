@@ -2,6 +2,9 @@ package mikrotik
 
 import (
 	"net/url"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -63,3 +66,146 @@ func TestDNSRecordFilter_toQueryParams(t *testing.T) {
 		})
 	}
 }
+
+func TestDNSRecordFilter_matches(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter DNSRecordFilter
+		record DNSRecord
+		want   bool
+	}{
+		{
+			name:   "managed type when empty",
+			filter: DNSRecordFilter{},
+			record: DNSRecord{Name: "host.example.com", Type: "CNAME"},
+			want:   true,
+		},
+		{
+			name:   "unmanaged type when empty",
+			filter: DNSRecordFilter{},
+			record: DNSRecord{Name: "host.example.com", Type: "FWD"},
+			want:   false,
+		},
+		{
+			name:   "one of multiple types",
+			filter: DNSRecordFilter{Type: "A,AAAA"},
+			record: DNSRecord{Name: "host.example.com", Type: "AAAA"},
+			want:   true,
+		},
+		{
+			name:   "matching name",
+			filter: DNSRecordFilter{Name: "host.example.com", Type: "A"},
+			record: DNSRecord{Name: "host.example.com", Type: "A"},
+			want:   true,
+		},
+		{
+			name:   "different name",
+			filter: DNSRecordFilter{Name: "host.example.com", Type: "A"},
+			record: DNSRecord{Name: "other.example.com", Type: "A"},
+			want:   false,
+		},
+		{
+			name:   "matching target",
+			filter: DNSRecordFilter{Name: "example.com", Type: "MX", Target: "10 mail.example.com"},
+			record: DNSRecord{Name: "example.com", Type: "MX", MXPreference: "10", MXExchange: "mail.example.com"},
+			want:   true,
+		},
+		{
+			name:   "different target",
+			filter: DNSRecordFilter{Name: "host.example.com", Type: "A", Target: "1.1.1.1"},
+			record: DNSRecord{Name: "host.example.com", Type: "A", Address: "2.2.2.2"},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(tt.record); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSRecordQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		filters   []DNSRecordFilter
+		want      []string
+		expectErr bool
+	}{
+		{
+			name:    "single name and type",
+			filters: []DNSRecordFilter{{Name: "host.example.com", Type: "A"}},
+			want:    []string{"type=A", "name=host.example.com", "#&"},
+		},
+		{
+			name:    "default types",
+			filters: []DNSRecordFilter{{}},
+			want:    []string{"type=A", "type=AAAA", "type=CNAME", "type=TXT", "type=MX", "type=SRV", "type=NS", "#|", "#|", "#|", "#|", "#|", "#|"},
+		},
+		{
+			name: "several names and targets",
+			filters: []DNSRecordFilter{
+				{Name: "a.example.com", Type: "A", Target: "1.1.1.1"},
+				{Name: "example.com", Type: "MX", Target: "10 mail.example.com"},
+				{Name: "b.example.com", Type: "CNAME"},
+			},
+			want: []string{
+				"type=A", "name=a.example.com", "address=1.1.1.1", "#&", "#&",
+				"type=MX", "name=example.com", "mx-exchange=mail.example.com", "mx-preference=10", "#&", "#&", "#&",
+				"type=CNAME", "name=b.example.com", "#&",
+				"#|", "#|",
+			},
+		},
+		{
+			name:      "target without a single type",
+			filters:   []DNSRecordFilter{{Name: "host.example.com", Target: "1.1.1.1"}},
+			expectErr: true,
+		},
+		{
+			name:      "invalid target",
+			filters:   []DNSRecordFilter{{Name: "host.example.com", Type: "A", Target: "not-an-ip"}},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dnsRecordQuery(tt.filters)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got query %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("dnsRecordQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSRecordColumns(t *testing.T) {
+	var tags []string
+	recordType := reflect.TypeFor[DNSRecord]()
+	for i := range recordType.NumField() {
+		if !recordType.Field(i).IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(recordType.Field(i).Tag.Get("json"), ",")
+		tags = append(tags, name)
+	}
+
+	if !slices.Equal(dnsRecordColumns, tags) {
+		t.Errorf("Expected the columns %v to match the fields of DNSRecord %v", dnsRecordColumns, tags)
+	}
+	for _, column := range dnsRecordTargetColumns {
+		if !slices.Contains(dnsRecordColumns, column) {
+			t.Errorf("Expected target column %s to be a field of DNSRecord", column)
+		}
+	}
+}
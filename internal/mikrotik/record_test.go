@@ -1058,3 +1058,56 @@ func TestExternalDNSEndpointToDNSRecord(t *testing.T) {
 		})
 	}
 }
+
+func TestDNSRecordChangedFields(t *testing.T) {
+	current := &DNSRecord{
+		ID:          "*1",
+		Name:        "example.com",
+		Type:        "A",
+		Address:     "1.2.3.4",
+		TTL:         "1h",
+		Comment:     "old",
+		AddressList: "list",
+		Disabled:    "false",
+	}
+
+	tests := []struct {
+		name     string
+		desired  *DNSRecord
+		expected map[string]string
+	}{
+		{
+			name:     "Identical record",
+			desired:  &DNSRecord{Name: "example.com", Type: "A", Address: "1.2.3.4", TTL: "60m", Comment: "old", AddressList: "list"},
+			expected: map[string]string{},
+		},
+		{
+			name:     "Changed target and comment",
+			desired:  &DNSRecord{Name: "example.com", Type: "A", Address: "5.6.7.8", TTL: "1h", Comment: "new", AddressList: "list"},
+			expected: map[string]string{"address": "5.6.7.8", "comment": "new"},
+		},
+		{
+			name:     "Cleared address list and disabled record",
+			desired:  &DNSRecord{Name: "example.com", Type: "A", Address: "1.2.3.4", TTL: "1h", Comment: "old", Disabled: "true"},
+			expected: map[string]string{"address-list": "", "disabled": "true"},
+		},
+		{
+			name:     "Changed TTL",
+			desired:  &DNSRecord{Name: "example.com", Type: "A", Address: "1.2.3.4", TTL: "1d", Comment: "old", AddressList: "list"},
+			expected: map[string]string{"ttl": "1d"},
+		},
+		{
+			name:     "Zero TTL leaves the TTL untouched",
+			desired:  &DNSRecord{Name: "example.com", Type: "A", Address: "1.2.3.4", TTL: "0s", Comment: "old", AddressList: "list"},
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := current.changedFields(tt.desired)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, fields)
+		})
+	}
+}
@@ -0,0 +1,568 @@
+// API Docs: https://help.mikrotik.com/docs/display/ROS/API
+
+package mikrotik
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	routerOSAPIScheme    = "api"
+	routerOSAPITLSScheme = "apis"
+	routerOSAPIPort      = "8728"
+	routerOSAPITLSPort   = "8729"
+)
+
+// routerOSSingletonMenus lists the menus for which the REST API returns a single object instead of a list
+var routerOSSingletonMenus = map[string]bool{
+	"system/resource": true,
+	"system/identity": true,
+	"ip/dns":          true,
+}
+
+// routerOSAPITransport is an http.RoundTripper that serves REST requests (i.e. GET /rest/ip/dns/static)
+// over the binary RouterOS API protocol, for routers where the www/www-ssl services are disabled.
+// It is registered for the api:// (plain, port 8728) and apis:// (TLS, port 8729) URL schemes.
+type routerOSAPITransport struct {
+	dialer              *net.Dialer
+	tlsConfig           *tls.Config
+	tlsHandshakeTimeout time.Duration
+
+	mu    sync.Mutex
+	conns map[string]*routerOSAPIConn
+}
+
+// routerOSAPIConn is a logged-in connection to the RouterOS API. Sentences are not tagged, so requests
+// sharing a connection are serialized.
+type routerOSAPIConn struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// routerOSAPIReply is a single reply sentence (i.e. !re, !done, !trap) along with its attributes
+type routerOSAPIReply struct {
+	word       string
+	attributes map[string]string
+}
+
+// newRouterOSAPITransport creates a new transport for the RouterOS API
+func newRouterOSAPITransport(dialer *net.Dialer, tlsConfig *tls.Config, tlsHandshakeTimeout time.Duration) *routerOSAPITransport {
+	return &routerOSAPITransport{
+		dialer:              dialer,
+		tlsConfig:           tlsConfig,
+		tlsHandshakeTimeout: tlsHandshakeTimeout,
+		conns:               map[string]*routerOSAPIConn{},
+	}
+}
+
+// RoundTrip translates a REST request into RouterOS API sentences and the replies back into a REST response.
+func (t *routerOSAPITransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	username, password, _ := req.BasicAuth()
+	key := fmt.Sprintf("%s://%s@%s", req.URL.Scheme, username, req.URL.Host)
+
+	t.mu.Lock()
+	c, ok := t.conns[key]
+	if !ok {
+		c = &routerOSAPIConn{}
+		t.conns[key] = c
+	}
+	t.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := t.connect(req.Context(), c, req.URL, username, password); err != nil {
+			err = routerOSAPIContextError(req.Context(), err)
+			var trap *routerOSAPITrap
+			if errors.As(err, &trap) {
+				return newRouterOSAPIResponse(req, http.StatusUnauthorized, map[string]any{
+					"error": http.StatusUnauthorized, "message": http.StatusText(http.StatusUnauthorized), "detail": trap.message,
+				}), nil
+			}
+			return nil, err
+		}
+	}
+
+	// The API protocol has no notion of cancellation, so abort any pending I/O once the request is done
+	conn := c.conn
+	if deadline, ok := req.Context().Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(req.Context(), func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+	defer func() {
+		stop()
+		_ = conn.SetDeadline(time.Time{})
+	}()
+
+	resp, err := t.execute(c, req)
+	if err != nil {
+		err = routerOSAPIContextError(req.Context(), err)
+		// The connection is in an unknown state, so drop it and let the next request reconnect
+		log.Debugf("dropping RouterOS API connection to %s: %v", req.URL.Host, err)
+		_ = c.conn.Close()
+		c.conn = nil
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// connect dials the router and logs in with the given credentials
+func (t *routerOSAPITransport) connect(ctx context.Context, c *routerOSAPIConn, u *url.URL, username, password string) error {
+	host := u.Host
+	if u.Port() == "" {
+		port := routerOSAPIPort
+		if u.Scheme == routerOSAPITLSScheme {
+			port = routerOSAPITLSPort
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	log.Debugf("connecting to RouterOS API at %s://%s", u.Scheme, host)
+
+	conn, err := t.dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme == routerOSAPITLSScheme {
+		tlsConfig := t.tlsConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+
+		handshakeCtx := ctx
+		if t.tlsHandshakeTimeout > 0 {
+			var cancel context.CancelFunc
+			handshakeCtx, cancel = context.WithTimeout(ctx, t.tlsHandshakeTimeout)
+			defer cancel()
+		}
+
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+			_ = conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+
+	// Bound the login by the request deadline, RoundTrip takes over once connected
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+
+	replies, err := c.run([]string{"/login", "=name=" + username, "=password=" + password})
+	if trap := replies.trap(); err == nil && trap != nil {
+		err = trap
+	}
+	if err != nil {
+		_ = conn.Close()
+		c.conn = nil
+		return err
+	}
+
+	return nil
+}
+
+// routerOSAPIContextError reports I/O errors caused by the request context being done as the context error.
+// The connection deadline may expire slightly before the context itself, so a past deadline counts as well.
+func routerOSAPIContextError(ctx context.Context, err error) error {
+	ctxErr := ctx.Err()
+	if deadline, ok := ctx.Deadline(); ctxErr == nil && ok && !time.Now().Before(deadline) && errors.Is(err, os.ErrDeadlineExceeded) {
+		ctxErr = context.DeadlineExceeded
+	}
+	if ctxErr == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ctxErr, err)
+}
+
+// execute maps the REST request onto the equivalent API command
+func (t *routerOSAPITransport) execute(c *routerOSAPIConn, req *http.Request) (*http.Response, error) {
+	path := strings.Trim(strings.TrimPrefix(req.URL.Path, "/rest"), "/")
+	menu, id := path, ""
+	if i := strings.LastIndex(path, "/"); i >= 0 && strings.HasPrefix(path[i+1:], "*") {
+		menu, id = path[:i], path[i+1:]
+	}
+
+	var body map[string]any
+	if req.Body != nil {
+		defer req.Body.Close()
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			if err := json.Unmarshal(data, &body); err != nil {
+				return newRouterOSAPIError(req, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err)), nil
+			}
+		}
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		sentence := []string{"/" + menu + "/print"}
+		if id != "" {
+			sentence = append(sentence, "?.id="+id)
+		} else {
+			sentence = append(sentence, queryStringToAPIWords(req.URL.Query())...)
+		}
+		replies, err := c.run(sentence)
+		if err != nil {
+			return nil, err
+		}
+		if trap := replies.trap(); trap != nil {
+			return newRouterOSAPITrapResponse(req, trap), nil
+		}
+		if id != "" || routerOSSingletonMenus[menu] {
+			items := replies.items()
+			if len(items) == 0 {
+				return newRouterOSAPIError(req, http.StatusNotFound, "no such item"), nil
+			}
+			return newRouterOSAPIResponse(req, http.StatusOK, items[0]), nil
+		}
+		return newRouterOSAPIResponse(req, http.StatusOK, replies.items()), nil
+
+	case http.MethodPut:
+		replies, err := c.run(append([]string{"/" + path + "/add"}, bodyToAPIWords(body)...))
+		if err != nil {
+			return nil, err
+		}
+		if trap := replies.trap(); trap != nil {
+			return newRouterOSAPITrapResponse(req, trap), nil
+		}
+		return t.printItem(c, req, path, replies.ret())
+
+	case http.MethodPatch:
+		replies, err := c.run(append([]string{"/" + menu + "/set", "=.id=" + id}, bodyToAPIWords(body)...))
+		if err != nil {
+			return nil, err
+		}
+		if trap := replies.trap(); trap != nil {
+			return newRouterOSAPITrapResponse(req, trap), nil
+		}
+		return t.printItem(c, req, menu, id)
+
+	case http.MethodDelete:
+		replies, err := c.run([]string{"/" + menu + "/remove", "=.id=" + id})
+		if err != nil {
+			return nil, err
+		}
+		if trap := replies.trap(); trap != nil {
+			return newRouterOSAPITrapResponse(req, trap), nil
+		}
+		return newRouterOSAPIResponse(req, http.StatusNoContent, nil), nil
+
+	case http.MethodPost:
+		replies, err := c.run(append([]string{"/" + path}, bodyToAPIWords(body)...))
+		if err != nil {
+			return nil, err
+		}
+		if trap := replies.trap(); trap != nil {
+			return newRouterOSAPITrapResponse(req, trap), nil
+		}
+		if items := replies.items(); len(items) > 0 {
+			return newRouterOSAPIResponse(req, http.StatusOK, items), nil
+		}
+		if ret := replies.ret(); ret != "" {
+			return newRouterOSAPIResponse(req, http.StatusOK, map[string]string{"ret": ret}), nil
+		}
+		return newRouterOSAPIResponse(req, http.StatusOK, []map[string]string{}), nil
+
+	default:
+		return newRouterOSAPIError(req, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not supported over the RouterOS API", req.Method)), nil
+	}
+}
+
+// printItem fetches a single item by ID, the way the REST API returns it after creating or updating it
+func (t *routerOSAPITransport) printItem(c *routerOSAPIConn, req *http.Request, menu, id string) (*http.Response, error) {
+	replies, err := c.run([]string{"/" + menu + "/print", "?.id=" + id})
+	if err != nil {
+		return nil, err
+	}
+	if trap := replies.trap(); trap != nil {
+		return newRouterOSAPITrapResponse(req, trap), nil
+	}
+	items := replies.items()
+	if len(items) == 0 {
+		return newRouterOSAPIError(req, http.StatusNotFound, "no such item"), nil
+	}
+	return newRouterOSAPIResponse(req, http.StatusOK, items[0]), nil
+}
+
+// ================================================================================================
+// SENTENCES
+// ================================================================================================
+// routerOSAPIReplies holds every reply sentence received for a command, up to and including !done
+type routerOSAPIReplies []routerOSAPIReply
+
+// routerOSAPITrap is the error reported by the router in a !trap reply
+type routerOSAPITrap struct {
+	message string
+}
+
+func (e *routerOSAPITrap) Error() string {
+	return fmt.Sprintf("RouterOS API error: %s", e.message)
+}
+
+// trap returns the first error reported by the router, if any
+func (r routerOSAPIReplies) trap() *routerOSAPITrap {
+	for _, reply := range r {
+		if reply.word == "!trap" {
+			return &routerOSAPITrap{message: reply.attributes["message"]}
+		}
+	}
+	return nil
+}
+
+// items returns the attributes of every !re reply
+func (r routerOSAPIReplies) items() []map[string]string {
+	items := []map[string]string{}
+	for _, reply := range r {
+		if reply.word == "!re" {
+			items = append(items, reply.attributes)
+		}
+	}
+	return items
+}
+
+// ret returns the value returned by the command in the !done reply (i.e. the ID of an added item)
+func (r routerOSAPIReplies) ret() string {
+	for _, reply := range r {
+		if reply.word == "!done" {
+			return reply.attributes["ret"]
+		}
+	}
+	return ""
+}
+
+// run sends a sentence and reads every reply until the command completes
+func (c *routerOSAPIConn) run(sentence []string) (routerOSAPIReplies, error) {
+	log.Debugf("sending RouterOS API command: %s", sentence[0])
+
+	var buf bytes.Buffer
+	for _, word := range sentence {
+		writeAPIWord(&buf, word)
+	}
+	writeAPIWord(&buf, "")
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	var replies routerOSAPIReplies
+	for {
+		reply, err := c.readReply()
+		if err != nil {
+			return nil, err
+		}
+		if reply.word == "!fatal" {
+			return nil, fmt.Errorf("RouterOS API connection closed by the router: %s", reply.attributes["message"])
+		}
+		replies = append(replies, reply)
+		if reply.word == "!done" {
+			return replies, nil
+		}
+	}
+}
+
+// readReply reads a single reply sentence
+func (c *routerOSAPIConn) readReply() (routerOSAPIReply, error) {
+	reply := routerOSAPIReply{attributes: map[string]string{}}
+	for {
+		word, err := readAPIWord(c.reader)
+		if err != nil {
+			return reply, err
+		}
+		if word == "" {
+			return reply, nil
+		}
+
+		switch {
+		case reply.word == "":
+			reply.word = word
+		case strings.HasPrefix(word, "="):
+			if key, value, ok := strings.Cut(word[1:], "="); ok {
+				reply.attributes[key] = value
+			}
+		default:
+			// !fatal carries its message as a plain word
+			reply.attributes["message"] = word
+		}
+	}
+}
+
+// writeAPIWord appends a length-prefixed word to the buffer
+func writeAPIWord(buf *bytes.Buffer, word string) {
+	length := uint32(len(word))
+	var prefix [5]byte
+	switch {
+	case length < 0x80:
+		buf.WriteByte(byte(length))
+	case length < 0x4000:
+		binary.BigEndian.PutUint16(prefix[:], uint16(length|0x8000))
+		buf.Write(prefix[:2])
+	case length < 0x200000:
+		binary.BigEndian.PutUint32(prefix[:], length|0xC00000)
+		buf.Write(prefix[1:4])
+	case length < 0x10000000:
+		binary.BigEndian.PutUint32(prefix[:], length|0xE0000000)
+		buf.Write(prefix[:4])
+	default:
+		prefix[0] = 0xF0
+		binary.BigEndian.PutUint32(prefix[1:], length)
+		buf.Write(prefix[:5])
+	}
+	buf.WriteString(word)
+}
+
+// readAPIWord reads a single length-prefixed word
+func readAPIWord(r *bufio.Reader) (string, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	var length uint32
+	var extra int
+	switch {
+	case first&0x80 == 0x00:
+		length = uint32(first)
+	case first&0xC0 == 0x80:
+		length, extra = uint32(first&0x3F), 1
+	case first&0xE0 == 0xC0:
+		length, extra = uint32(first&0x1F), 2
+	case first&0xF0 == 0xE0:
+		length, extra = uint32(first&0x0F), 3
+	case first == 0xF0:
+		length, extra = 0, 4
+	default:
+		return "", fmt.Errorf("invalid RouterOS API word length prefix: %#x", first)
+	}
+
+	for range extra {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		length = length<<8 | uint32(b)
+	}
+
+	word := make([]byte, length)
+	if _, err := io.ReadFull(r, word); err != nil {
+		return "", err
+	}
+	return string(word), nil
+}
+
+// ================================================================================================
+// UTILS
+// ================================================================================================
+// queryStringToAPIWords converts REST query parameters into API query words.
+// Comma-separated values are OR-ed together, different parameters are AND-ed.
+func queryStringToAPIWords(query url.Values) []string {
+	var words []string
+	for _, key := range slices.Sorted(maps.Keys(query)) {
+		value := query.Get(key)
+		if key == ".proplist" {
+			words = append(words, "=.proplist="+value)
+			continue
+		}
+
+		values := strings.Split(value, ",")
+		for _, v := range values {
+			words = append(words, fmt.Sprintf("?%s=%s", key, v))
+		}
+		for range len(values) - 1 {
+			words = append(words, "?#|")
+		}
+	}
+	return words
+}
+
+// bodyToAPIWords converts a REST request body into API attribute and query words
+func bodyToAPIWords(body map[string]any) []string {
+	var words []string
+	for _, key := range slices.Sorted(maps.Keys(body)) {
+		switch value := body[key].(type) {
+		case []any:
+			var values []string
+			for _, v := range value {
+				values = append(values, fmt.Sprint(v))
+			}
+			if key == ".query" {
+				for _, v := range values {
+					words = append(words, "?"+v)
+				}
+			} else {
+				words = append(words, fmt.Sprintf("=%s=%s", key, strings.Join(values, ",")))
+			}
+		case nil:
+			words = append(words, fmt.Sprintf("=%s=", key))
+		default:
+			words = append(words, fmt.Sprintf("=%s=%v", key, value))
+		}
+	}
+	return words
+}
+
+// newRouterOSAPIResponse builds a REST-like JSON response
+func newRouterOSAPIResponse(req *http.Request, status int, payload any) *http.Response {
+	var body []byte
+	if payload != nil {
+		body, _ = json.Marshal(payload)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// newRouterOSAPIError builds a REST-like error response
+func newRouterOSAPIError(req *http.Request, status int, detail string) *http.Response {
+	return newRouterOSAPIResponse(req, status, map[string]any{
+		"error":   status,
+		"message": http.StatusText(status),
+		"detail":  detail,
+	})
+}
+
+// newRouterOSAPITrapResponse builds the REST error response matching a !trap reply
+func newRouterOSAPITrapResponse(req *http.Request, trap *routerOSAPITrap) *http.Response {
+	status := http.StatusBadRequest
+	if strings.Contains(trap.message, "no such item") {
+		status = http.StatusNotFound
+	}
+	return newRouterOSAPIError(req, status, trap.message)
+}
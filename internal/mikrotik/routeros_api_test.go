@@ -0,0 +1,430 @@
+package mikrotik
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// fakeRouterOSAPI is a minimal RouterOS API server holding static DNS entries
+type fakeRouterOSAPI struct {
+	listener net.Listener
+	mu       sync.Mutex
+	records  []map[string]string
+	nextID   int
+}
+
+func newFakeRouterOSAPI(t *testing.T, records ...map[string]string) *fakeRouterOSAPI {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	f := &fakeRouterOSAPI{listener: listener, records: records, nextID: len(records) + 1}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+
+	return f
+}
+
+func (f *fakeRouterOSAPI) URL() string {
+	return "api://" + f.listener.Addr().String()
+}
+
+func (f *fakeRouterOSAPI) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	loggedIn := false
+
+	for {
+		var sentence []string
+		for {
+			word, err := readAPIWord(reader)
+			if err != nil {
+				return
+			}
+			if word == "" {
+				break
+			}
+			sentence = append(sentence, word)
+		}
+
+		attributes := map[string]string{}
+		var queries []string
+		for _, word := range sentence[1:] {
+			switch {
+			case strings.HasPrefix(word, "="):
+				key, value, _ := strings.Cut(word[1:], "=")
+				attributes[key] = value
+			case strings.HasPrefix(word, "?"):
+				queries = append(queries, word[1:])
+			}
+		}
+
+		var replies [][]string
+		switch {
+		case sentence[0] == "/login":
+			if attributes["name"] == mockUsername && attributes["password"] == mockPassword {
+				loggedIn = true
+				replies = append(replies, []string{"!done"})
+			} else {
+				replies = append(replies, []string{"!trap", "=message=invalid user name or password (6)"}, []string{"!done"})
+			}
+		case !loggedIn:
+			replies = append(replies, []string{"!fatal", "not logged in"})
+		default:
+			replies = f.handle(sentence[0], attributes, queries)
+		}
+
+		var buf bytes.Buffer
+		for _, reply := range replies {
+			for _, word := range reply {
+				writeAPIWord(&buf, word)
+			}
+			writeAPIWord(&buf, "")
+		}
+		if _, err := conn.Write(buf.Bytes()); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRouterOSAPI) handle(command string, attributes map[string]string, queries []string) [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch command {
+	case "/system/resource/print":
+		return [][]string{{"!re", "=board-name=CHR", "=version=7.16 (stable)"}, {"!done"}}
+
+	case "/ip/dns/static/print":
+		var replies [][]string
+		for _, record := range f.records {
+			if !matchAPIQueries(record, queries) {
+				continue
+			}
+			reply := []string{"!re"}
+			for key, value := range record {
+				if proplist, ok := attributes[".proplist"]; ok && !slices.Contains(strings.Split(proplist, ","), key) {
+					continue
+				}
+				reply = append(reply, fmt.Sprintf("=%s=%s", key, value))
+			}
+			replies = append(replies, reply)
+		}
+		return append(replies, []string{"!done"})
+
+	case "/ip/dns/static/add":
+		record := map[string]string{".id": fmt.Sprintf("*%X", f.nextID)}
+		f.nextID++
+		for key, value := range attributes {
+			record[key] = value
+		}
+		f.records = append(f.records, record)
+		return [][]string{{"!done", "=ret=" + record[".id"]}}
+
+	case "/ip/dns/static/remove":
+		for i, record := range f.records {
+			if record[".id"] == attributes[".id"] {
+				f.records = append(f.records[:i], f.records[i+1:]...)
+				return [][]string{{"!done"}}
+			}
+		}
+		return [][]string{{"!trap", "=message=no such item"}, {"!done"}}
+
+	default:
+		return [][]string{{"!trap", "=message=no such command"}, {"!done"}}
+	}
+}
+
+// matchAPIQueries evaluates API query words (i.e. type=A, #|) against a record
+func matchAPIQueries(record map[string]string, queries []string) bool {
+	var stack []bool
+	for _, query := range queries {
+		switch query {
+		case "#|", "#&":
+			a, b := stack[len(stack)-2], stack[len(stack)-1]
+			stack = stack[:len(stack)-2]
+			if query == "#|" {
+				stack = append(stack, a || b)
+			} else {
+				stack = append(stack, a && b)
+			}
+		default:
+			key, value, _ := strings.Cut(query, "=")
+			stack = append(stack, record[key] == value)
+		}
+	}
+	for _, result := range stack {
+		if !result {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRouterOSAPITransport(t *testing.T) {
+	router := newFakeRouterOSAPI(t,
+		map[string]string{".id": "*1", "name": "example.com", "type": "A", "address": "1.2.3.4", "ttl": "1h"},
+		map[string]string{".id": "*2", "name": "www.example.com", "type": "CNAME", "cname": "example.com", "ttl": "1h"},
+		map[string]string{".id": "*3", "name": "example.com", "type": "FWD", "forward-to": "1.1.1.1", "ttl": "1h"},
+	)
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrl:  router.URL(),
+		Username: mockUsername,
+		Password: mockPassword,
+	}, &MikrotikDefaults{DefaultTTL: 3600})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	info, err := client.GetSystemInfo(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error fetching system info, got %v", err)
+	}
+	if info.BoardName != "CHR" {
+		t.Errorf("Expected board name CHR, got %s", info.BoardName)
+	}
+
+	records, err := client.GetDNSRecords(context.Background(), DNSRecordFilter{Name: "example.com"})
+	if err != nil {
+		t.Fatalf("Expected no error fetching records, got %v", err)
+	}
+	if len(records) != 1 || records[0].ID != "*1" {
+		t.Errorf("Expected only the A record of example.com, got %+v", records)
+	}
+
+	created, err := client.CreateRecordsFromEndpoint(context.Background(), &endpoint.Endpoint{
+		DNSName:    "new.example.com",
+		RecordType: "A",
+		Targets:    endpoint.NewTargets("5.6.7.8"),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error creating records, got %v", err)
+	}
+	if len(created) != 1 || created[0].ID == "" || created[0].Address != "5.6.7.8" || created[0].TTL != "1h" {
+		t.Errorf("Expected the created record to be returned with its ID and default TTL, got %+v", created)
+	}
+
+	if err := client.DeleteRecordsFromEndpoint(context.Background(), &endpoint.Endpoint{
+		DNSName:    "example.com",
+		RecordType: "A",
+		Targets:    endpoint.NewTargets("1.2.3.4"),
+	}); err != nil {
+		t.Fatalf("Expected no error deleting records, got %v", err)
+	}
+
+	records, err = client.GetDNSRecords(context.Background(), DNSRecordFilter{})
+	if err != nil {
+		t.Fatalf("Expected no error fetching records, got %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("Expected 2 records left, got %+v", records)
+	}
+
+	if err := client.DeleteDNSRecord(context.Background(), "*1"); err != nil {
+		t.Errorf("Expected deleting a missing record to succeed, got %v", err)
+	}
+}
+
+func TestRouterOSAPITransportInvalidCredentials(t *testing.T) {
+	router := newFakeRouterOSAPI(t)
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrl:  router.URL(),
+		Username: mockUsername,
+		Password: "wrongpass",
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if _, err := client.GetSystemInfo(context.Background()); err == nil {
+		t.Fatalf("Expected error due to invalid credentials, got none")
+	}
+}
+
+func TestRouterOSAPIWordEncoding(t *testing.T) {
+	for _, length := range []int{0, 1, 0x7F, 0x80, 0x3FFF, 0x4000, 0x1FFFFF, 0x200000} {
+		t.Run(fmt.Sprintf("length %d", length), func(t *testing.T) {
+			word := strings.Repeat("a", length)
+
+			var buf bytes.Buffer
+			writeAPIWord(&buf, word)
+
+			decoded, err := readAPIWord(bufio.NewReader(&buf))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if decoded != word {
+				t.Errorf("Expected word of length %d, got length %d", length, len(decoded))
+			}
+		})
+	}
+}
+
+func TestQueryStringToAPIWords(t *testing.T) {
+	filter := DNSRecordFilter{Name: "example.com", Type: "A,AAAA", Columns: targetColumns}
+	values, err := url.ParseQuery(filter.toQueryParams())
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+
+	expected := []string{"=.proplist=" + strings.Join(dnsRecordTargetColumns, ","), "?name=example.com", "?type=A", "?type=AAAA", "?#|"}
+	words := queryStringToAPIWords(values)
+	if strings.Join(words, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected words %v, got %v", expected, words)
+	}
+
+	var queries []string
+	for _, word := range words {
+		if query, ok := strings.CutPrefix(word, "?"); ok {
+			queries = append(queries, query)
+		}
+	}
+	if !matchAPIQueries(map[string]string{"name": "example.com", "type": "AAAA"}, queries) {
+		t.Errorf("Expected AAAA record of example.com to match %v", words)
+	}
+	if matchAPIQueries(map[string]string{"name": "example.com", "type": "TXT"}, queries) {
+		t.Errorf("Expected TXT record of example.com not to match %v", words)
+	}
+}
+
+func TestRouterOSAPITransportQuery(t *testing.T) {
+	router := newFakeRouterOSAPI(t,
+		map[string]string{".id": "*1", "name": "a.example.com", "type": "A", "address": "1.1.1.1", "ttl": "1h"},
+		map[string]string{".id": "*2", "name": "a.example.com", "type": "A", "address": "2.2.2.2", "ttl": "1h"},
+		map[string]string{".id": "*3", "name": "b.example.com", "type": "CNAME", "cname": "a.example.com", "ttl": "1h"},
+		map[string]string{".id": "*4", "name": "b.example.com", "type": "TXT", "text": "hello", "ttl": "1h"},
+		map[string]string{".id": "*5", "name": "c.example.com", "type": "A", "address": "3.3.3.3", "ttl": "1h"},
+	)
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrl:  router.URL(),
+		Username: mockUsername,
+		Password: mockPassword,
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	records, err := client.QueryDNSRecords(context.Background(), []DNSRecordFilter{
+		{Name: "a.example.com", Type: "A", Target: "2.2.2.2"},
+		{Name: "b.example.com", Type: "CNAME"},
+		{Name: "missing.example.com", Type: "A"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error querying records, got %v", err)
+	}
+
+	var ids []string
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	if strings.Join(ids, ",") != "*2,*3" {
+		t.Errorf("Expected records *2 and *3, got %+v", records)
+	}
+}
+
+func TestRouterOSAPITransportColumns(t *testing.T) {
+	router := newFakeRouterOSAPI(t,
+		map[string]string{".id": "*1", "name": "example.com", "type": "A", "address": "1.2.3.4", "ttl": "1h", "comment": "external-dns", "dynamic": "false"},
+	)
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrl:  router.URL(),
+		Username: mockUsername,
+		Password: mockPassword,
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	seriesBefore := testutil.CollectAndCount(recordsResponseBytes)
+
+	full, err := client.GetDNSRecords(context.Background(), DNSRecordFilter{Name: "example.com", Type: "A"})
+	if err != nil {
+		t.Fatalf("Expected no error fetching records, got %v", err)
+	}
+	if len(full) != 1 || full[0].Comment != "external-dns" || full[0].TTL != "1h" {
+		t.Errorf("Expected every column of the record, got %+v", full)
+	}
+
+	light, err := client.QueryDNSRecords(context.Background(), []DNSRecordFilter{{Name: "example.com", Type: "A", Columns: targetColumns}})
+	if err != nil {
+		t.Fatalf("Expected no error querying records, got %v", err)
+	}
+	if len(light) != 1 || light[0] != (DNSRecord{ID: "*1", Name: "example.com", Type: "A", Address: "1.2.3.4"}) {
+		t.Errorf("Expected only the ID, name, type and target of the record, got %+v", light)
+	}
+
+	// One series for each set of columns fetched from this router
+	if series := testutil.CollectAndCount(recordsResponseBytes); series != seriesBefore+2 {
+		t.Errorf("Expected the size of both responses to be recorded, got %d new series", series-seriesBefore)
+	}
+}
+
+func TestRouterOSAPITransportCancellation(t *testing.T) {
+	// A router accepting connections but never answering
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrl:  "api://" + listener.Addr().String(),
+		Username: mockUsername,
+		Password: mockPassword,
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.GetSystemInfo(ctx)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the request to be aborted once the context is done")
+	}
+}
@@ -0,0 +1,284 @@
+package mikrotik
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// snapshotDNSClient is a DNSClient keeping a snapshot of the managed records of a router, so that lookups
+// do not fetch the whole static DNS table every time. The snapshot is fetched again once older than maxAge,
+// kept up to date with the records created, updated and deleted through the client, and dropped as soon as
+// any request fails, since the state of the router is then unknown.
+type snapshotDNSClient struct {
+	DNSClient
+	maxAge time.Duration
+	// scope limits the snapshot to the names and types of a change set, nil for every managed record.
+	// Lookups outside of the scope are sent to the router.
+	scope []DNSRecordFilter
+
+	// fetchMu ensures a single fetch is in progress, the other lookups waiting to use its result
+	fetchMu sync.Mutex
+
+	mu        sync.Mutex
+	records   []DNSRecord
+	byName    map[string][]int
+	fetchedAt time.Time
+	valid     bool
+	// generation changes on every mutation, so that a fetch racing with one is not kept
+	generation uint64
+}
+
+// newSnapshotDNSClient wraps a client with a snapshot of its records, kept for up to maxAge
+func newSnapshotDNSClient(client DNSClient, maxAge time.Duration) *snapshotDNSClient {
+	return &snapshotDNSClient{DNSClient: client, maxAge: maxAge}
+}
+
+// newChangeSetSnapshot wraps a client with the records fetched for the names and types of a change set,
+// kept until the change set is applied
+func newChangeSetSnapshot(client DNSClient, scope []DNSRecordFilter, records []DNSRecord) *snapshotDNSClient {
+	c := &snapshotDNSClient{
+		DNSClient: client,
+		maxAge:    time.Duration(math.MaxInt64),
+		scope:     scope,
+		records:   records,
+		fetchedAt: time.Now(),
+		valid:     true,
+	}
+	c.reindex()
+	return c
+}
+
+// GetDNSRecords returns the records matching the filter from the snapshot, fetching it first if needed
+func (c *snapshotDNSClient) GetDNSRecords(ctx context.Context, filter DNSRecordFilter) ([]DNSRecord, error) {
+	if !c.covers(filter) {
+		return c.DNSClient.GetDNSRecords(ctx, filter)
+	}
+	return c.get(ctx, filter)
+}
+
+// QueryDNSRecords returns the records matching any of the filters from the snapshot, fetching it first if needed
+func (c *snapshotDNSClient) QueryDNSRecords(ctx context.Context, filters []DNSRecordFilter) ([]DNSRecord, error) {
+	if slices.ContainsFunc(filters, func(filter DNSRecordFilter) bool { return !c.covers(filter) }) {
+		return c.DNSClient.QueryDNSRecords(ctx, filters)
+	}
+	return c.get(ctx, filters...)
+}
+
+// get returns the records matching any of the filters from the snapshot, fetching it first if needed
+func (c *snapshotDNSClient) get(ctx context.Context, filters ...DNSRecordFilter) ([]DNSRecord, error) {
+	if records, ok := c.lookup(filters); ok {
+		return records, nil
+	}
+
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+
+	// The snapshot may have been fetched while waiting
+	if records, ok := c.lookup(filters); ok {
+		return records, nil
+	}
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	var records []DNSRecord
+	var err error
+	if c.scope == nil {
+		records, err = c.DNSClient.GetDNSRecords(ctx, DNSRecordFilter{})
+	} else {
+		records, err = c.DNSClient.QueryDNSRecords(ctx, c.scope)
+	}
+	if err != nil {
+		c.invalidate(err)
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		log.Debugf("fetched a snapshot of %d DNS records from router %s", len(records), c)
+		c.records = records
+		c.fetchedAt = time.Now()
+		c.valid = true
+		c.reindex()
+	} else {
+		log.Debugf("DNS records of router %s changed while fetching them, not keeping the snapshot", c)
+	}
+	c.mu.Unlock()
+
+	return filterRecords(records, filters...), nil
+}
+
+// CreateDNSRecord creates the record and adds it to the snapshot
+func (c *snapshotDNSClient) CreateDNSRecord(ctx context.Context, record *DNSRecord) (*DNSRecord, error) {
+	created, err := c.DNSClient.CreateDNSRecord(ctx, record)
+	c.mutated(err, func() {
+		c.put(*created)
+	})
+	return created, err
+}
+
+// CreateDNSRecords creates the records and adds the created ones to the snapshot
+func (c *snapshotDNSClient) CreateDNSRecords(ctx context.Context, records []*DNSRecord) ([]*DNSRecord, error) {
+	created, err := c.DNSClient.CreateDNSRecords(ctx, records)
+	c.mutated(err, func() {
+		for _, record := range created {
+			if record != nil {
+				c.put(*record)
+			}
+		}
+	})
+	return created, err
+}
+
+// UpdateDNSRecord updates the record and replaces it in the snapshot
+func (c *snapshotDNSClient) UpdateDNSRecord(ctx context.Context, id string, fields map[string]string) (*DNSRecord, error) {
+	updated, err := c.DNSClient.UpdateDNSRecord(ctx, id, fields)
+	c.mutated(err, func() {
+		for i := range c.records {
+			if c.records[i].ID == id && updated != nil {
+				c.records[i] = *updated
+			}
+		}
+	})
+	return updated, err
+}
+
+// DeleteDNSRecord deletes the record and removes it from the snapshot
+func (c *snapshotDNSClient) DeleteDNSRecord(ctx context.Context, id string) error {
+	err := c.DNSClient.DeleteDNSRecord(ctx, id)
+	snapshotErr := err
+	if IsNotFound(err) {
+		// The record is gone either way
+		snapshotErr = nil
+	}
+	c.mutated(snapshotErr, func() {
+		c.records = slices.DeleteFunc(c.records, func(r DNSRecord) bool { return r.ID == id })
+	})
+	return err
+}
+
+// DeleteDNSRecords deletes the records and removes them from the snapshot
+func (c *snapshotDNSClient) DeleteDNSRecords(ctx context.Context, ids []string) error {
+	err := c.DNSClient.DeleteDNSRecords(ctx, ids)
+	c.mutated(err, func() {
+		c.records = slices.DeleteFunc(c.records, func(r DNSRecord) bool { return slices.Contains(ids, r.ID) })
+	})
+	return err
+}
+
+// put adds a created record to the snapshot, or replaces it if an existing record was taken over.
+// Callers must hold the lock.
+func (c *snapshotDNSClient) put(record DNSRecord) {
+	record.takenOver = nil
+	if i := slices.IndexFunc(c.records, func(r DNSRecord) bool { return r.ID == record.ID }); i >= 0 {
+		c.records[i] = record
+		return
+	}
+	c.records = append(c.records, record)
+}
+
+// Ready reports the readiness of the wrapped client, if it can tell
+func (c *snapshotDNSClient) Ready() error {
+	if reporter, ok := c.DNSClient.(readinessReporter); ok {
+		return reporter.Ready()
+	}
+	return nil
+}
+
+// covers tells whether the records matching the filter are all part of the snapshot
+func (c *snapshotDNSClient) covers(filter DNSRecordFilter) bool {
+	return c.scope == nil || slices.ContainsFunc(c.scope, func(scope DNSRecordFilter) bool {
+		return filter.Name != "" && filter.Name == scope.Name && filter.Type == scope.Type
+	})
+}
+
+// lookup returns the records matching any of the filters if the snapshot is fresh enough
+func (c *snapshotDNSClient) lookup(filters []DNSRecordFilter) ([]DNSRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.valid || time.Since(c.fetchedAt) > c.maxAge {
+		return nil, false
+	}
+
+	if slices.ContainsFunc(filters, func(filter DNSRecordFilter) bool { return filter.Name == "" }) {
+		return filterRecords(c.records, filters...), true
+	}
+
+	// Only look at the records with the names of the filters, in their order within the snapshot
+	var indexes []int
+	for _, filter := range filters {
+		indexes = append(indexes, c.byName[filter.Name]...)
+	}
+	slices.Sort(indexes)
+	candidates := make([]DNSRecord, 0, len(indexes))
+	for _, i := range slices.Compact(indexes) {
+		candidates = append(candidates, c.records[i])
+	}
+	return filterRecords(candidates, filters...), true
+}
+
+// mutated applies a mutation to the snapshot if the request succeeded, or drops the snapshot if it failed
+func (c *snapshotDNSClient) mutated(err error, apply func()) {
+	if err != nil {
+		c.invalidate(err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if c.valid {
+		apply()
+		c.reindex()
+	}
+}
+
+// invalidate drops the snapshot after a failed request
+func (c *snapshotDNSClient) invalidate(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if c.valid {
+		log.Debugf("dropping the snapshot of the DNS records of router %s after a failed request: %v", c, err)
+	}
+	c.valid = false
+	c.records = nil
+	c.byName = nil
+}
+
+// reindex rebuilds the index of the records by name. The caller must hold the lock.
+func (c *snapshotDNSClient) reindex() {
+	c.byName = map[string][]int{}
+	for i, record := range c.records {
+		c.byName[record.Name] = append(c.byName[record.Name], i)
+	}
+}
+
+// uncached returns the client reaching the router itself, bypassing its snapshot of every record if it has one.
+// The snapshot of a change set is kept, as it was fetched right before applying the change set.
+func uncached(client DNSClient) DNSClient {
+	if snapshot, ok := client.(*snapshotDNSClient); ok && snapshot.scope == nil {
+		return snapshot.DNSClient
+	}
+	return client
+}
+
+// filterRecords returns a copy of the records matching any of the filters
+func filterRecords(records []DNSRecord, filters ...DNSRecordFilter) []DNSRecord {
+	filtered := []DNSRecord{}
+	for _, record := range records {
+		if slices.ContainsFunc(filters, func(filter DNSRecordFilter) bool { return filter.matches(record) }) {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,187 @@
+package mikrotik
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// countingClient counts record fetches and queries, and fails updates while failUpdates is set
+type countingClient struct {
+	*MemoryDNSClient
+	fetches     int
+	queries     int
+	failUpdates bool
+}
+
+func (c *countingClient) GetDNSRecords(ctx context.Context, filter DNSRecordFilter) ([]DNSRecord, error) {
+	c.fetches++
+	return c.MemoryDNSClient.GetDNSRecords(ctx, filter)
+}
+
+func (c *countingClient) QueryDNSRecords(ctx context.Context, filters []DNSRecordFilter) ([]DNSRecord, error) {
+	c.queries++
+	return c.MemoryDNSClient.QueryDNSRecords(ctx, filters)
+}
+
+func (c *countingClient) UpdateDNSRecord(ctx context.Context, id string, fields map[string]string) (*DNSRecord, error) {
+	if c.failUpdates {
+		return nil, fmt.Errorf("failure: router is read-only")
+	}
+	return c.MemoryDNSClient.UpdateDNSRecord(ctx, id, fields)
+}
+
+// addresses returns the sorted addresses of the records
+func addresses(records []DNSRecord) []string {
+	var result []string
+	for _, record := range records {
+		result = append(result, record.Name+"="+record.Address)
+	}
+	slices.Sort(result)
+	return result
+}
+
+func TestSnapshotDNSClient(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingClient{MemoryDNSClient: NewMemoryDNSClient(
+		DNSRecord{Name: "a.example.com", Type: "A", Address: "1.1.1.1"},
+		DNSRecord{Name: "a.example.com", Type: "AAAA", Address: "::1"},
+		DNSRecord{Name: "b.example.com", Type: "A", Address: "2.2.2.2"},
+	)}
+	client := newSnapshotDNSClient(inner, time.Minute)
+
+	assertLookup := func(filter DNSRecordFilter, expected []string, expectedFetches int) {
+		t.Helper()
+		records, err := client.GetDNSRecords(ctx, filter)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := addresses(records); !slices.Equal(got, expected) {
+			t.Errorf("Expected records %v for %+v, got %v", expected, filter, got)
+		}
+		if inner.fetches != expectedFetches {
+			t.Errorf("Expected %d fetches, got %d", expectedFetches, inner.fetches)
+		}
+	}
+
+	// Lookups are served from a single fetch
+	assertLookup(DNSRecordFilter{}, []string{"a.example.com=1.1.1.1", "a.example.com=::1", "b.example.com=2.2.2.2"}, 1)
+	assertLookup(DNSRecordFilter{Name: "a.example.com", Type: "A"}, []string{"a.example.com=1.1.1.1"}, 1)
+	assertLookup(DNSRecordFilter{Name: "missing.example.com", Type: "A"}, nil, 1)
+
+	// Our own changes are applied to the snapshot
+	created, err := client.CreateDNSRecords(ctx, []*DNSRecord{{Name: "c.example.com", Type: "A", Address: "3.3.3.3"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := client.UpdateDNSRecord(ctx, created[0].ID, map[string]string{"address": "4.4.4.4"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	records, _ := client.GetDNSRecords(ctx, DNSRecordFilter{Name: "b.example.com", Type: "A"})
+	if err := client.DeleteDNSRecords(ctx, []string{records[0].ID}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assertLookup(DNSRecordFilter{Type: "A"}, []string{"a.example.com=1.1.1.1", "c.example.com=4.4.4.4"}, 1)
+
+	// A failed request drops the snapshot
+	inner.failUpdates = true
+	if _, err := client.UpdateDNSRecord(ctx, created[0].ID, map[string]string{"address": "5.5.5.5"}); err == nil {
+		t.Fatalf("Expected the update to fail")
+	}
+	inner.failUpdates = false
+	assertLookup(DNSRecordFilter{Type: "A"}, []string{"a.example.com=1.1.1.1", "c.example.com=4.4.4.4"}, 2)
+
+	// Changes made behind our back are seen once the snapshot expires
+	if _, err := inner.CreateDNSRecord(ctx, &DNSRecord{Name: "d.example.com", Type: "A", Address: "6.6.6.6"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	assertLookup(DNSRecordFilter{Name: "d.example.com", Type: "A"}, nil, 2)
+	client.mu.Lock()
+	client.fetchedAt = client.fetchedAt.Add(-client.maxAge)
+	client.mu.Unlock()
+	assertLookup(DNSRecordFilter{Name: "d.example.com", Type: "A"}, []string{"d.example.com=6.6.6.6"}, 3)
+}
+
+func TestMikrotikProvider_ApplyChanges_Snapshot(t *testing.T) {
+	inner := &countingClient{MemoryDNSClient: NewMemoryDNSClient(
+		DNSRecord{Name: "a.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h"},
+		DNSRecord{Name: "b.example.com", Type: "A", Address: "2.2.2.2", TTL: "1h"},
+		DNSRecord{Name: "c.example.com", Type: "A", Address: "3.3.3.3", TTL: "1h"},
+	)}
+
+	p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{DefaultTTL: 3600}, withSnapshot(inner, time.Minute))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := p.Records(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{NewEndpoint("d.example.com", []string{"4.4.4.4"}, "A", 3600, nil)},
+		Delete: []*endpoint.Endpoint{
+			NewEndpoint("a.example.com", []string{"1.1.1.1"}, "A", 3600, nil),
+			NewEndpoint("b.example.com", []string{"2.2.2.2"}, "A", 3600, nil),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	endpoints, err := p.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if inner.fetches != 1 {
+		t.Errorf("Expected the records to be fetched once, got %d fetches", inner.fetches)
+	}
+	var names []string
+	for _, ep := range endpoints {
+		names = append(names, ep.DNSName)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"c.example.com", "d.example.com"}) {
+		t.Errorf("Expected the snapshot to reflect the changes, got %v", names)
+	}
+	if got := addresses(inner.Records()); !slices.Equal(got, []string{"c.example.com=3.3.3.3", "d.example.com=4.4.4.4"}) {
+		t.Errorf("Expected the changes to be applied to the router, got %v", got)
+	}
+}
+
+func TestMikrotikProvider_ApplyChanges_ChangeSetSnapshot(t *testing.T) {
+	inner := &countingClient{MemoryDNSClient: NewMemoryDNSClient(
+		DNSRecord{Name: "a.example.com", Type: "A", Address: "1.1.1.1", TTL: "1h"},
+		DNSRecord{Name: "b.example.com", Type: "A", Address: "2.2.2.2", TTL: "1h"},
+		DNSRecord{Name: "c.example.com", Type: "A", Address: "3.3.3.3", TTL: "1h"},
+		DNSRecord{Name: "unrelated.example.com", Type: "A", Address: "9.9.9.9", TTL: "1h"},
+	)}
+
+	p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{DefaultTTL: 3600}, inner)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	p.(*MikrotikProvider).checkDeleteConflicts = true
+
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create:    []*endpoint.Endpoint{NewEndpoint("d.example.com", []string{"4.4.4.4"}, "A", 3600, nil)},
+		UpdateOld: []*endpoint.Endpoint{NewEndpoint("b.example.com", []string{"2.2.2.2"}, "A", 3600, nil)},
+		UpdateNew: []*endpoint.Endpoint{NewEndpoint("b.example.com", []string{"5.5.5.5"}, "A", 3600, nil)},
+		Delete:    []*endpoint.Endpoint{NewEndpoint("a.example.com", []string{"1.1.1.1"}, "A", 3600, nil)},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if inner.queries != 1 || inner.fetches != 0 {
+		t.Errorf("Expected the change set to be resolved by a single query, got %d queries and %d fetches", inner.queries, inner.fetches)
+	}
+	expected := []string{"b.example.com=5.5.5.5", "c.example.com=3.3.3.3", "d.example.com=4.4.4.4", "unrelated.example.com=9.9.9.9"}
+	if got := addresses(inner.Records()); !slices.Equal(got, expected) {
+		t.Errorf("Expected records %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,65 @@
+package mikrotik
+
+import (
+	"sync"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// forEachInGroups calls fn for every item, processing up to workers groups of items concurrently.
+// Items with the same key form a group, processed one after another in their original order by a single worker.
+// The errors returned by fn are returned in the order of the items, whatever the order they completed in.
+// With 1 worker or less, every item is processed in order on the calling goroutine.
+func forEachInGroups[T any](workers int, items []T, key func(T) string, fn func(i int, item T) error) []error {
+	errs := make([]error, len(items))
+
+	if workers <= 1 || len(items) <= 1 {
+		for i, item := range items {
+			errs[i] = fn(i, item)
+		}
+		return errs
+	}
+
+	// Group the items by key, in order of first appearance
+	var groups [][]int
+	groupOf := map[string]int{}
+	for i, item := range items {
+		k := key(item)
+		g, ok := groupOf[k]
+		if !ok {
+			g = len(groups)
+			groupOf[k] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	queue := make(chan []int)
+	var wg sync.WaitGroup
+	for range min(workers, len(groups)) {
+		wg.Go(func() {
+			for group := range queue {
+				for _, i := range group {
+					errs[i] = fn(i, items[i])
+				}
+			}
+		})
+	}
+	for _, group := range groups {
+		queue <- group
+	}
+	close(queue)
+	wg.Wait()
+
+	return errs
+}
+
+// endpointKey identifies the name and type of an endpoint, whose changes must be applied in order
+func endpointKey(ep *endpoint.Endpoint) string {
+	return ep.RecordType + "::" + ep.DNSName
+}
+
+// recordKey identifies the name and type of a DNS record, like endpointKey
+func recordKey(record *DNSRecord) string {
+	return record.Type + "::" + recordName(record)
+}
@@ -0,0 +1,111 @@
+package mikrotik
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestForEachInGroups(t *testing.T) {
+	items := []string{"a1", "b1", "a2", "c1", "b2", "a3"}
+	key := func(item string) string { return item[:1] }
+
+	for _, workers := range []int{1, 3} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			var mu sync.Mutex
+			order := map[string][]string{}
+			var running, maxRunning atomic.Int32
+
+			// The first items wait for every worker to be busy, so that they all run at once
+			allBusy := make(chan struct{})
+			var closeAllBusy sync.Once
+			errs := forEachInGroups(workers, items, key, func(i int, item string) error {
+				current := running.Add(1)
+				defer running.Add(-1)
+				for {
+					seen := maxRunning.Load()
+					if current <= seen || maxRunning.CompareAndSwap(seen, current) {
+						break
+					}
+				}
+				if int(current) == workers {
+					closeAllBusy.Do(func() { close(allBusy) })
+				}
+				<-allBusy
+
+				mu.Lock()
+				order[key(item)] = append(order[key(item)], item)
+				mu.Unlock()
+
+				if item[1] == '2' {
+					return fmt.Errorf("item %d failed", i)
+				}
+				return nil
+			})
+
+			if !slices.Equal(order["a"], []string{"a1", "a2", "a3"}) || !slices.Equal(order["b"], []string{"b1", "b2"}) {
+				t.Errorf("Expected items with the same key to be processed in order, got %v", order)
+			}
+			if got := int(maxRunning.Load()); got != workers {
+				t.Errorf("Expected %d items to be processed concurrently, got %d", workers, got)
+			}
+
+			var messages []string
+			for _, err := range errs {
+				if err != nil {
+					messages = append(messages, err.Error())
+				}
+			}
+			if !slices.Equal(messages, []string{"item 2 failed", "item 4 failed"}) {
+				t.Errorf("Expected errors in the order of the items, got %v", messages)
+			}
+		})
+	}
+}
+
+func TestMikrotikProvider_ApplyChanges_Workers(t *testing.T) {
+	var existing []DNSRecord
+	changes := &plan.Changes{}
+	for i := range 20 {
+		name := fmt.Sprintf("host%d.example.com", i)
+		existing = append(existing, DNSRecord{Name: name, Type: "A", Address: fmt.Sprintf("10.0.0.%d", i), TTL: "1h"})
+		changes.Create = append(changes.Create, NewEndpoint("new-"+name, []string{fmt.Sprintf("10.1.0.%d", i)}, "A", 3600, nil))
+		changes.UpdateOld = append(changes.UpdateOld, NewEndpoint(name, []string{fmt.Sprintf("10.0.0.%d", i)}, "A", 3600, nil))
+		changes.UpdateNew = append(changes.UpdateNew, NewEndpoint(name, []string{fmt.Sprintf("10.2.0.%d", i)}, "A", 3600, nil))
+	}
+	client := NewMemoryDNSClient(existing...)
+
+	p, err := NewMikrotikProviderWithClient(nil, &MikrotikDefaults{DefaultTTL: 3600}, client)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	p.(*MikrotikProvider).applyWorkers = 4
+
+	if err := p.ApplyChanges(context.Background(), changes); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	endpoints, err := p.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	targets := map[string]endpoint.Targets{}
+	for _, ep := range endpoints {
+		targets[ep.DNSName] = ep.Targets
+	}
+	for i := range 20 {
+		name := fmt.Sprintf("host%d.example.com", i)
+		if !slices.Equal(targets[name], endpoint.Targets{fmt.Sprintf("10.2.0.%d", i)}) {
+			t.Errorf("Expected %s to be updated, got %v", name, targets[name])
+		}
+		if !slices.Equal(targets["new-"+name], endpoint.Targets{fmt.Sprintf("10.1.0.%d", i)}) {
+			t.Errorf("Expected new-%s to be created, got %v", name, targets["new-"+name])
+		}
+	}
+}
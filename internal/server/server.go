@@ -26,15 +26,34 @@ func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	_, err := w.Write([]byte("OK"))
-	if err != nil {
-		log.Errorf("error writing response: %v", err)
+// ReadinessChecker is implemented by providers able to tell whether they can currently serve requests
+type ReadinessChecker interface {
+	Ready() error
+}
+
+// ReadinessHandler reports the service as not ready while the checker, if any, returns an error
+func ReadinessHandler(checker ReadinessChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if checker != nil {
+			if err := checker.Ready(); err != nil {
+				log.Debugf("readiness check failed: %v", err)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				if _, err := w.Write([]byte(err.Error())); err != nil {
+					log.Errorf("error writing response: %v", err)
+				}
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte("OK"))
+		if err != nil {
+			log.Errorf("error writing response: %v", err)
+		}
 	}
 }
 
-func Init(config configuration.Config, p *webhook.Webhook) (*http.Server, *http.Server) {
+func Init(config configuration.Config, p *webhook.Webhook, readiness ReadinessChecker) (*http.Server, *http.Server) {
 	mainRouter := chi.NewRouter()
 	mainRouter.Get("/", p.Negotiate)
 	mainRouter.Get("/records", p.Records)
@@ -52,7 +71,7 @@ func Init(config configuration.Config, p *webhook.Webhook) (*http.Server, *http.
 	healthRouter := chi.NewRouter()
 	healthRouter.Get("/metrics", promhttp.Handler().ServeHTTP)
 	healthRouter.Get("/healthz", HealthCheckHandler)
-	healthRouter.Get("/readyz", ReadinessHandler)
+	healthRouter.Get("/readyz", ReadinessHandler(readiness))
 
 	healthServer := createHTTPServer("0.0.0.0:8080", healthRouter, config.ServerReadTimeout, config.ServerWriteTimeout)
 	go func() {
@@ -25,6 +25,7 @@ func main() {
 		log.Fatalf("failed to initialize provider: %v", err)
 	}
 
-	main, health := server.Init(config, webhook.New(provider))
+	readiness, _ := provider.(server.ReadinessChecker)
+	main, health := server.Init(config, webhook.New(provider), readiness)
 	server.ShutdownGracefully(main, health)
 }
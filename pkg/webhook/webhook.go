@@ -2,6 +2,7 @@ package webhook
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -22,6 +23,36 @@ const (
 	logFieldError         = "error"
 )
 
+// upstreamError is implemented by errors returned by the DNS server behind the provider, such as
+// mikrotik.MikrotikAPIError, along with the HTTP status it answered with
+type upstreamError interface {
+	error
+	HTTPStatus() int
+}
+
+// partialSuccess is implemented by errors returned by providers that applied every change except for the
+// endpoints they skipped, such as mikrotik.SkippedEndpointsError
+type partialSuccess interface {
+	error
+	SkippedEndpoints() int
+}
+
+// errorStatus returns the status to answer with when the provider fails. It is always a 5xx status, which
+// external-dns treats as a transient failure to retry on its next run, rather than a fatal one.
+func errorStatus(err error) int {
+	var upstreamErr upstreamError
+	if !errors.As(err, &upstreamErr) {
+		return http.StatusInternalServerError
+	}
+
+	switch upstreamErr.HTTPStatus() {
+	case http.StatusServiceUnavailable, http.StatusTooManyRequests:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
 // Webhook for external dns provider
 type Webhook struct {
 	provider provider.Provider
@@ -103,7 +134,7 @@ func (p *Webhook) Records(w http.ResponseWriter, r *http.Request) {
 	records, err := p.provider.Records(ctx)
 	if err != nil {
 		requestLog(r).WithField(logFieldError, err).Error("error getting records")
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		return
 	}
 
@@ -141,9 +172,21 @@ func (p *Webhook) ApplyChanges(w http.ResponseWriter, r *http.Request) {
 
 	requestLog(r).Debugf("requesting apply changes, create: %d , updateOld: %d, updateNew: %d, delete: %d",
 		len(changes.Create), len(changes.UpdateOld), len(changes.UpdateNew), len(changes.Delete))
-	if err := p.provider.ApplyChanges(ctx, &changes); err != nil {
+	err := p.provider.ApplyChanges(ctx, &changes)
+	// Retrying would only skip the same endpoints again, so a partial success is answered like a success.
+	// external-dns treats any other status as a failure, so the skipped endpoints are only logged.
+	if skipped, ok := err.(partialSuccess); ok {
+		requestLog(r).WithField(logFieldError, err).Warnf("applied changes, skipping %d endpoints", skipped.SkippedEndpoints())
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		requestLog(r).WithField(logFieldError, err).Error("error applying changes")
 		w.Header().Set(contentTypeHeader, contentTypePlaintext)
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
+		if _, writeError := fmt.Fprint(w, err.Error()); writeError != nil {
+			requestLog(r).WithField(logFieldError, writeError).Error("error writing error message to response writer")
+		}
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+
+	"github.com/mirceanton/external-dns-provider-mikrotik/internal/mikrotik"
+)
+
+// stubProvider answers ApplyChanges with a fixed error
+type stubProvider struct {
+	provider.BaseProvider
+	err error
+}
+
+func (p *stubProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	return nil, nil
+}
+
+func (p *stubProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	return p.err
+}
+
+func TestWebhook_ApplyChanges(t *testing.T) {
+	skipped := &mikrotik.SkippedEndpointsError{Endpoints: []*mikrotik.EndpointError{{
+		Endpoint: endpoint.NewEndpoint("bad.example.com", "A", "300.1.1.1"),
+		Err:      errors.New("invalid IPv4 address"),
+	}}}
+	upstream := func(status int) error {
+		return fmt.Errorf("router: %w", &mikrotik.MikrotikAPIError{StatusCode: status, Status: fmt.Sprintf("%d %s", status, http.StatusText(status))})
+	}
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "Success", err: nil, expectedStatus: http.StatusNoContent},
+		{name: "Only skipped endpoints", err: skipped, expectedStatus: http.StatusNoContent},
+		{name: "Skipped endpoints and a failure", err: errors.Join(errors.New("router failed"), skipped), expectedStatus: http.StatusInternalServerError},
+		{name: "Failure", err: errors.New("failed"), expectedStatus: http.StatusInternalServerError},
+		{name: "Router unavailable", err: upstream(http.StatusServiceUnavailable), expectedStatus: http.StatusServiceUnavailable},
+		{name: "Router rate limiting", err: upstream(http.StatusTooManyRequests), expectedStatus: http.StatusServiceUnavailable},
+		{name: "Router refusing the request", err: upstream(http.StatusBadRequest), expectedStatus: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook := New(&stubProvider{err: tt.err})
+
+			r := httptest.NewRequest(http.MethodPost, "/records", strings.NewReader(`{}`))
+			r.Header.Set(contentTypeHeader, string(mediaTypeVersion1))
+			w := httptest.NewRecorder()
+			webhook.ApplyChanges(w, r)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}